@@ -0,0 +1,154 @@
+package yenc
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// sortByBegin returns a copy of parts sorted by Begin.
+func sortByBegin(parts []*Part) []*Part {
+	sorted := slices.Clone(parts)
+	slices.SortStableFunc(sorted, func(a, b *Part) int {
+		return cmp.Compare(a.Begin, b.Begin)
+	})
+	return sorted
+}
+
+// CheckContiguous checks that the [Begin,End] ranges of parts,
+// sorted by Begin, follow each other without gaps or overlaps.
+// two parts with the same Begin return *ErrDuplicateOffset.
+// parts is not modified.
+func CheckContiguous(parts []*Part) error {
+	sorted := sortByBegin(parts)
+	for i, part := range sorted {
+		if part.Begin < 1 || part.End < part.Begin {
+			return fmt.Errorf("%w: part %d has begin=%d end=%d", ErrInvalidRange, part.Number, part.Begin, part.End)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := sorted[i-1]
+		switch {
+		case part.Begin == prev.Begin:
+			return &ErrDuplicateOffset{Part1: prev.Number, Part2: part.Number, Begin: part.Begin}
+		case part.Begin > prev.End+1:
+			return fmt.Errorf("%w: part %d ends at %d but part %d begins at %d", ErrGap, prev.Number, prev.End, part.Number, part.Begin)
+		case part.Begin <= prev.End:
+			return fmt.Errorf("%w: part %d ends at %d but part %d begins at %d", ErrOverlap, prev.Number, prev.End, part.Number, part.Begin)
+		}
+	}
+	return nil
+} // end func CheckContiguous
+
+// Assembler writes decoded parts of a multipart file to their
+// offset in w as they arrive, so only one body has to be held
+// in memory at a time. an Assembler is not safe for concurrent use.
+type Assembler struct {
+	w io.WriterAt
+	// ranges of the parts written so far, without bodies
+	parts []*Part
+	// size of the whole file from =ybegin size=, 0 if unknown
+	size int64
+}
+
+func NewAssembler(w io.WriterAt) *Assembler {
+	return &Assembler{w: w}
+} // end func yenc.NewAssembler
+
+// AddPart writes the body of p to offset Begin-1.
+// parts can be added in any order, a part which overlaps a part
+// added before is rejected with ErrOverlap or *ErrDuplicateOffset.
+func (a *Assembler) AddPart(p *Part) error {
+	if p.Begin < 1 || p.End < p.Begin || p.bodyLen() != p.End-p.Begin+1 {
+		return fmt.Errorf("%w: part %d has begin=%d end=%d and %d bytes", ErrInvalidRange, p.Number, p.Begin, p.End, p.bodyLen())
+	}
+	added := &Part{Number: p.Number, Begin: p.Begin, End: p.End}
+	for _, q := range a.parts {
+		switch {
+		case q.Begin == added.Begin:
+			return &ErrDuplicateOffset{Part1: q.Number, Part2: added.Number, Begin: added.Begin}
+		case q.Begin <= added.End && added.Begin <= q.End:
+			return fmt.Errorf("%w: part %d has %d-%d but part %d has %d-%d", ErrOverlap, q.Number, q.Begin, q.End, added.Number, added.Begin, added.End)
+		}
+	}
+	if _, err := p.WriteTo(io.NewOffsetWriter(a.w, p.Begin-1)); err != nil {
+		return fmt.Errorf("Error in yenc.Assembler.AddPart: part %d: %w", p.Number, err)
+	}
+	a.parts = append(a.parts, added)
+	if p.HeaderSize > 0 {
+		a.size = p.HeaderSize
+	}
+	return nil
+} // end func a.AddPart
+
+// Complete returns true if total parts have been added and
+// they cover the file from the first byte without gaps
+// (up to =ybegin size= if the parts carried it).
+func (a *Assembler) Complete(total int) bool {
+	if total < 1 || len(a.parts) != total {
+		return false
+	}
+	sorted := sortByBegin(a.parts)
+	if sorted[0].Begin != 1 || CheckContiguous(sorted) != nil {
+		return false
+	}
+	return a.size == 0 || sorted[len(sorted)-1].End == a.size
+} // end func a.Complete
+
+// Concat returns the bodies of the parts of one file concatenated
+// in the order of Begin. the parts must cover the file from the first
+// byte without gaps or overlaps, see CheckContiguous. a single part
+// file (Number 0) is returned as it is. parts is not modified.
+func Concat(parts []*Part) ([]byte, error) {
+	var data []byte
+	appendBody := func(b []byte) error {
+		data = append(data, b...)
+		return nil
+	}
+	if len(parts) == 1 && parts[0].Number == 0 {
+		if err := parts[0].ForEachChunk(appendBody); err != nil {
+			return nil, fmt.Errorf("Error in yenc.Concat: %w", err)
+		}
+		return data, nil
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("Error in yenc.Concat: %w: no parts", ErrInvalidRange)
+	}
+	if err := CheckContiguous(parts); err != nil {
+		return nil, fmt.Errorf("Error in yenc.Concat: %w", err)
+	}
+	sorted := sortByBegin(parts)
+	if sorted[0].Begin != 1 {
+		return nil, fmt.Errorf("Error in yenc.Concat: %w: first part %d begins at %d", ErrGap, sorted[0].Number, sorted[0].Begin)
+	}
+	data = make([]byte, 0, sorted[len(sorted)-1].End)
+	for _, p := range sorted {
+		if p.bodyLen() != p.End-p.Begin+1 {
+			return nil, fmt.Errorf("Error in yenc.Concat: %w: part %d has begin=%d end=%d and %d bytes", ErrInvalidRange, p.Number, p.Begin, p.End, p.bodyLen())
+		}
+		if err := p.ForEachChunk(appendBody); err != nil {
+			return nil, fmt.Errorf("Error in yenc.Concat: part %d: %w", p.Number, err)
+		}
+	}
+	return data, nil
+} // end func Concat
+
+// TotalSize returns the length of the file the parts belong to as
+// far as their ranges tell: the highest End, which is 1-based and
+// inclusive, e.g. to preallocate the output before writing the parts
+// at Begin-1. a single part file without a range counts its decoded
+// bytes. the last part must be present for the result to be the
+// full length, see Part.HeaderSize for the announced size.
+func TotalSize(parts []*Part) int64 {
+	var size int64
+	for _, p := range parts {
+		if p.End == 0 && p.Number == 0 {
+			size = max(size, p.bodyLen())
+			continue
+		}
+		size = max(size, p.End)
+	}
+	return size
+} // end func TotalSize
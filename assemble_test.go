@@ -0,0 +1,250 @@
+package yenc
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckContiguous(t *testing.T) {
+	parts := []*Part{
+		{Number: 2, Begin: 101, End: 200},
+		{Number: 1, Begin: 1, End: 100},
+		{Number: 3, Begin: 201, End: 250},
+	}
+	if err := CheckContiguous(parts); err != nil {
+		t.Errorf("expected contiguous parts got %v", err)
+	}
+	if parts[0].Number != 2 {
+		t.Errorf("expected parts not to be reordered")
+	}
+	gap := []*Part{
+		{Number: 1, Begin: 1, End: 100},
+		{Number: 2, Begin: 102, End: 200},
+	}
+	if err := CheckContiguous(gap); !errors.Is(err, ErrGap) {
+		t.Errorf("expected ErrGap got %v", err)
+	}
+	overlap := []*Part{
+		{Number: 1, Begin: 1, End: 100},
+		{Number: 2, Begin: 100, End: 200},
+	}
+	if err := CheckContiguous(overlap); !errors.Is(err, ErrOverlap) {
+		t.Errorf("expected ErrOverlap got %v", err)
+	}
+}
+
+func TestCheckContiguousDuplicateOffset(t *testing.T) {
+	parts := []*Part{
+		{Number: 1, Begin: 1, End: 100},
+		{Number: 2, Begin: 101, End: 200},
+		{Number: 7, Begin: 101, End: 200},
+	}
+	err := CheckContiguous(parts)
+	var dupErr *ErrDuplicateOffset
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("expected ErrDuplicateOffset got %v", err)
+	}
+	if dupErr.Part1 != 2 || dupErr.Part2 != 7 || dupErr.Begin != 101 {
+		t.Errorf("expected parts 2 and 7 at 101 got %d and %d at %d", dupErr.Part1, dupErr.Part2, dupErr.Begin)
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	var full []byte
+	for _, part := range parts {
+		full = append(full, part.Body...)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "random.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	assembler := NewAssembler(out)
+	// shuffled and partial
+	for _, i := range []int{2, 0} {
+		if err := assembler.AddPart(parts[i]); err != nil {
+			t.Fatalf("expected to add part %d: %v", parts[i].Number, err)
+		}
+		if assembler.Complete(3) {
+			t.Fatalf("expected incomplete after part %d", parts[i].Number)
+		}
+	}
+	var dup *ErrDuplicateOffset
+	if err := assembler.AddPart(parts[0]); !errors.As(err, &dup) {
+		t.Errorf("expected ErrDuplicateOffset adding part 1 twice got %v", err)
+	}
+	overlap := &Part{Number: 9, Begin: 3000, End: 3499, Body: make([]byte, 500)}
+	if err := assembler.AddPart(overlap); !errors.Is(err, ErrOverlap) {
+		t.Errorf("expected ErrOverlap got %v", err)
+	}
+	if err := assembler.AddPart(parts[1]); err != nil {
+		t.Fatalf("expected to add part 2: %v", err)
+	}
+	if !assembler.Complete(3) || assembler.Complete(4) {
+		t.Errorf("expected complete with 3 parts")
+	}
+	got, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, full) {
+		t.Errorf("expected assembled file to match the decoded parts")
+	}
+}
+
+func TestConcat(t *testing.T) {
+	// multipart_test.yenc only has part 1 of joystick.jpg,
+	// multipart_full_test.yenc has all parts of random.bin
+	data := loadFixture(t, "multipart_full_test.yenc")
+	parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	var want []byte
+	for _, p := range parts {
+		want = append(want, p.Body...)
+	}
+	got, err := Concat([]*Part{parts[2], parts[0], parts[1]})
+	if err != nil {
+		t.Fatalf("expected to concat: %v", err)
+	}
+	if !bytes.Equal(got, want) || int64(len(got)) != parts[0].HeaderSize {
+		t.Errorf("expected %d bytes got %d", parts[0].HeaderSize, len(got))
+	}
+	if _, err := Concat([]*Part{parts[0], parts[2]}); !errors.Is(err, ErrGap) {
+		t.Errorf("expected ErrGap got %v", err)
+	}
+	if _, err := Concat([]*Part{parts[1], parts[2]}); !errors.Is(err, ErrGap) {
+		t.Errorf("expected ErrGap for a missing first part got %v", err)
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	var parts []*Part
+	for _, fn := range []string{"singlepart_test.yenc", "multipart_full_test.yenc"} {
+		data := loadFixture(t, fn)
+		decoded, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		parts = append(parts, decoded...)
+	}
+	want, err := Concat(parts[1:])
+	if err != nil {
+		t.Fatalf("expected to concat: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteTar(&buf, parts); err != nil {
+		t.Fatalf("expected to write tar: %v", err)
+	}
+	tr := tar.NewReader(&buf)
+	for _, w := range []struct {
+		name string
+		data []byte
+	}{
+		{parts[0].SafeName(), parts[0].Body},
+		{"random.bin", want},
+	} {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("expected entry %s: %v", w.name, err)
+		}
+		got, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("could not read entry %s: %v", w.name, err)
+		}
+		if hdr.Name != w.name || !bytes.Equal(got, w.data) {
+			t.Errorf("expected %s with %d bytes got %s with %d", w.name, len(w.data), hdr.Name, len(got))
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("expected 2 entries got more: %v", err)
+	}
+	// different names with the same SafeName
+	buf.Reset()
+	clash := []*Part{{Name: "dir/a.txt", Body: []byte("a")}, {Name: "a.txt", Body: []byte("b")}, {Name: "x/a.txt", Body: []byte("c")}}
+	if err := WriteTar(&buf, clash); err != nil {
+		t.Fatalf("expected to write tar: %v", err)
+	}
+	tr = tar.NewReader(&buf)
+	for _, name := range []string{"a.txt", "a (1).txt", "a (2).txt"} {
+		hdr, err := tr.Next()
+		if err != nil || hdr.Name != name {
+			t.Errorf("expected entry %s got %v err=%v", name, hdr, err)
+		}
+	}
+}
+
+func TestReconstructToMmap(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	want, err := Concat(parts)
+	if err != nil {
+		t.Fatalf("expected to concat: %v", err)
+	}
+	// shuffled
+	shuffled := []*Part{parts[2], parts[0], parts[1]}
+	dir := t.TempDir()
+	mmapped, written := filepath.Join(dir, "mmap.bin"), filepath.Join(dir, "writeat.bin")
+	if err := ReconstructToMmap(mmapped, int64(len(want)), shuffled); err != nil {
+		t.Fatalf("expected to reconstruct: %v", err)
+	}
+	if err := reconstruct(written, int64(len(want)), shuffled, false); err != nil {
+		t.Fatalf("expected to reconstruct with WriteAt: %v", err)
+	}
+	for _, path := range []string{mmapped, written} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: expected %d assembled bytes got %d", filepath.Base(path), len(want), len(got))
+		}
+	}
+	if err := ReconstructToMmap(mmapped, int64(len(want))-1, parts); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("expected ErrInvalidRange for a part beyond the size got %v", err)
+	}
+}
+
+func TestTotalSize(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	full, err := Concat(parts)
+	if err != nil {
+		t.Fatalf("expected to concat: %v", err)
+	}
+	// in any order
+	if got := TotalSize([]*Part{parts[2], parts[0], parts[1]}); got != int64(len(full)) || got != parts[0].HeaderSize {
+		t.Errorf("expected total size %d got %d", len(full), got)
+	}
+	if got := TotalSize(parts[:2]); got != parts[1].End {
+		t.Errorf("expected %d without the last part got %d", parts[1].End, got)
+	}
+	single := loadFixture(t, "singlepart_test.yenc")
+	part, err := NewDecoder(nil, single, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if got := TotalSize([]*Part{part}); got != 584 {
+		t.Errorf("expected 584 for a single part got %d", got)
+	}
+	if got := TotalSize(nil); got != 0 {
+		t.Errorf("expected 0 without parts got %d", got)
+	}
+}
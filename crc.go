@@ -0,0 +1,70 @@
+package yenc
+
+// CombineCRC32 returns the IEEE crc32 of the whole file from the
+// pcrc32 values of its parts and the lengths of their decoded data,
+// without hashing the bodies again. =yend size= is not used, it may be
+// wrong (see SizeIsEncoded). the parts are combined in order of Begin.
+// compare the result with the crc32= of the last part (see FinalCRC).
+func CombineCRC32(parts []*Part) uint32 {
+	var crc uint32
+	for _, p := range sortByBegin(parts) {
+		crc = crc32Combine(crc, p.Crc32, p.bodyLen())
+	}
+	return crc
+} // end func CombineCRC32
+
+// crc32Combine returns the crc of A+B from crc1 of A, crc2 of B and
+// the length of B, ported from zlib's crc32_combine.
+func crc32Combine(crc1, crc2 uint32, len2 int64) uint32 {
+	if len2 <= 0 {
+		return crc1
+	}
+	var even, odd [32]uint32
+	// operator for one zero bit in odd
+	odd[0] = 0xedb88320 // IEEE polynomial, reversed
+	row := uint32(1)
+	for n := 1; n < 32; n++ {
+		odd[n] = row
+		row <<= 1
+	}
+	// two zero bits in even, four zero bits in odd
+	gf2MatrixSquare(&even, &odd)
+	gf2MatrixSquare(&odd, &even)
+	// apply len2 zero bytes to crc1, the first square
+	// puts the operator for one zero byte in even
+	for {
+		gf2MatrixSquare(&even, &odd)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&even, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+		gf2MatrixSquare(&odd, &even)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&odd, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+	}
+	return crc1 ^ crc2
+}
+
+func gf2MatrixTimes(mat *[32]uint32, vec uint32) uint32 {
+	var sum uint32
+	for i := 0; vec != 0; i, vec = i+1, vec>>1 {
+		if vec&1 != 0 {
+			sum ^= mat[i]
+		}
+	}
+	return sum
+}
+
+func gf2MatrixSquare(square, mat *[32]uint32) {
+	for n := range square {
+		square[n] = gf2MatrixTimes(mat, mat[n])
+	}
+}
@@ -0,0 +1,311 @@
+package yenc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// DefaultLine is the line length used when encoding without EncodeOptions.Line
+const DefaultLine = 128
+
+type EncodeOptions struct {
+	// filename for =ybegin name=
+	Name string
+	// line length, <= 0 means DefaultLine
+	Line int
+	// escape constants, nil means StandardProfile
+	Profile *EscapeProfile
+	// size for =ybegin size= when streaming through an Encoder.
+	// 0 if unknown: the =yend trailer always has the real size.
+	// for multipart this is the size of the whole file.
+	Size int64
+	// multipart: part number (> 0 enables multipart headers),
+	// total number of parts (0 if unknown) and
+	// the 1-based range of the part in the file for =ypart
+	Part       int
+	Total      int
+	Begin, End int64
+	// write the output ready for posting to an NNTP server: a dot at
+	// the start of a line is dot-stuffed to ".." instead of escaped
+	// and Close ends the article with a line holding a lone ".".
+	// decode it with Decoder.NNTP.
+	NNTPDotStuff bool
+}
+
+func (o *EncodeOptions) line() int {
+	if o == nil || o.Line <= 0 {
+		return DefaultLine
+	}
+	return o.Line
+}
+
+func (o *EncodeOptions) profile() *EscapeProfile {
+	if o == nil || o.Profile == nil {
+		return &StandardProfile
+	}
+	return o.Profile
+}
+
+func (o *EncodeOptions) name() string {
+	if o == nil {
+		return ""
+	}
+	return o.Name
+}
+
+func (o *EncodeOptions) size() int64 {
+	if o == nil {
+		return 0
+	}
+	return o.Size
+}
+
+// Encoder streams data written to it as a yenc article.
+// the =ybegin header is written with the first Write,
+// the =yend trailer with size= and crc32= of all written
+// data is written by Close: the output is not valid yenc
+// until Close has been called!
+type Encoder struct {
+	w    *bufio.Writer
+	opts *EncodeOptions
+	line int
+	pr   *EscapeProfile
+	// column in the current output line
+	col int
+	// crc and size of the data written so far
+	crcHash hash.Hash32
+	size    int64
+	// the last byte is held back until we know
+	// whether it ends the data (trailing whitespace is escaped)
+	pending    byte
+	hasPending bool
+	began      bool
+	closed     bool
+	// EncodeOptions.NNTPDotStuff
+	dotStuff bool
+}
+
+func NewEncoder(w io.Writer, opts *EncodeOptions) *Encoder {
+	return &Encoder{
+		w:        bufio.NewWriter(w),
+		opts:     opts,
+		line:     opts.line(),
+		pr:       opts.profile(),
+		crcHash:  crc32.NewIEEE(),
+		dotStuff: opts != nil && opts.NNTPDotStuff,
+	}
+} // end func yenc.NewEncoder
+
+func (e *Encoder) begin() {
+	if e.began {
+		return
+	}
+	e.began = true
+	o := e.opts
+	if o == nil || o.Part <= 0 {
+		fmt.Fprintf(e.w, "=ybegin line=%d size=%d name=%s\r\n", e.line, o.size(), o.name())
+		return
+	}
+	if o.Total > 0 {
+		fmt.Fprintf(e.w, "=ybegin part=%d total=%d line=%d size=%d name=%s\r\n", o.Part, o.Total, e.line, o.Size, o.Name)
+	} else {
+		fmt.Fprintf(e.w, "=ybegin part=%d line=%d size=%d name=%s\r\n", o.Part, e.line, o.Size, o.Name)
+	}
+	if o.End > 0 {
+		fmt.Fprintf(e.w, "=ypart begin=%d end=%d\r\n", o.Begin, o.End)
+	} else {
+		fmt.Fprintf(e.w, "=ypart begin=%d\r\n", o.Begin)
+	}
+}
+
+// partSize returns the expected number of bytes to encode or 0 if unknown.
+func (o *EncodeOptions) partSize() int64 {
+	if o == nil {
+		return 0
+	}
+	if o.Part > 0 {
+		if o.End > 0 {
+			return o.End - o.Begin + 1
+		}
+		return 0
+	}
+	return o.Size
+}
+
+// Write encodes p.
+func (e *Encoder) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("Error in yenc.Encoder.Write: encoder closed")
+	}
+	e.begin()
+	for _, b := range p {
+		if e.hasPending {
+			e.encodeByte(e.pending, false)
+		}
+		e.pending, e.hasPending = b, true
+	}
+	e.crcHash.Write(p)
+	e.size += int64(len(p))
+	return len(p), nil
+}
+
+// Close writes the last body line and the =yend trailer.
+// it does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	e.begin()
+	if e.hasPending {
+		e.encodeByte(e.pending, true)
+		e.hasPending = false
+	}
+	if e.col > 0 {
+		e.w.WriteString("\r\n")
+		e.col = 0
+	}
+	if e.opts != nil && e.opts.Part > 0 {
+		fmt.Fprintf(e.w, "=yend size=%d part=%d pcrc32=%08x\r\n", e.size, e.opts.Part, e.crcHash.Sum32())
+	} else {
+		fmt.Fprintf(e.w, "=yend size=%d crc32=%08x\r\n", e.size, e.crcHash.Sum32())
+	}
+	if e.dotStuff {
+		e.w.WriteString(".\r\n")
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	if size := e.opts.partSize(); size > 0 && size != e.size {
+		return fmt.Errorf("Error in yenc.Encoder.Close: wrote %d bytes but header size=%d", e.size, size)
+	}
+	return nil
+} // end func e.Close
+
+// encodeByte writes one encoded byte, last is set for the last byte of the data.
+func (e *Encoder) encodeByte(b byte, last bool) {
+	pr := e.pr
+	c := b + pr.Offset
+	escape := false
+	switch c {
+	case 0x00, '\n', '\r', '=', pr.Escape:
+		escape = true
+	case '\t', ' ':
+		// whitespace at the start or end of a line may get stripped
+		escape = e.col == 0 || e.col == e.line-1 || last
+	case '.':
+		// a dot at column 0 collides with NNTP dot-stuffing
+		// the NNTP server strips the stuffed dot again
+		if e.col == 0 && e.dotStuff {
+			e.w.WriteByte('.')
+		}
+		escape = e.col == 0 && !e.dotStuff
+	}
+	if escape {
+		e.w.WriteByte(pr.Escape)
+		e.w.WriteByte(c + pr.EscapeOffset)
+		e.col += 2
+	} else {
+		e.w.WriteByte(c)
+		e.col++
+	}
+	if e.col >= e.line {
+		e.w.WriteString("\r\n")
+		e.col = 0
+	}
+}
+
+// Encode writes data as a single part yenc article to w.
+// the multipart fields of opts are ignored.
+func Encode(w io.Writer, data []byte, opts *EncodeOptions) error {
+	o := EncodeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Size = int64(len(data))
+	o.Part, o.Total, o.Begin, o.End = 0, 0, 0, 0
+	enc := NewEncoder(w, &o)
+	enc.Write(data)
+	return enc.Close()
+} // end func Encode
+
+// RoundTrip encodes data with opts, decodes the result again and returns
+// the decoded bytes, with an error if they differ from data. it is meant
+// for tests and verification tooling: every encoder change should
+// round-trip. Profile and NNTPDotStuff of opts are applied to the
+// decoder too. with opts.Part > 0 data is posted as that part, Begin,
+// End and Size default to the range of data. without a name
+// "roundtrip.bin" is used, the decoder rejects an empty one.
+func RoundTrip(data []byte, opts *EncodeOptions) ([]byte, error) {
+	o := EncodeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Name == "" {
+		o.Name = "roundtrip.bin"
+	}
+	var buf bytes.Buffer
+	if o.Part > 0 {
+		if o.Begin == 0 && o.End == 0 {
+			o.Begin, o.End = 1, int64(len(data))
+		}
+		if o.Size == 0 {
+			o.Size = o.End
+		}
+		enc := NewEncoder(&buf, &o)
+		if _, err := enc.Write(data); err != nil {
+			return nil, fmt.Errorf("Error in yenc.RoundTrip: encode err='%w'", err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("Error in yenc.RoundTrip: encode err='%w'", err)
+		}
+	} else if err := Encode(&buf, data, &o); err != nil {
+		return nil, fmt.Errorf("Error in yenc.RoundTrip: encode err='%w'", err)
+	}
+	d := NewDecoder(&buf, nil, nil, 1)
+	d.Profile, d.NNTP = o.Profile, o.NNTPDotStuff
+	d.ValidateFull = ValidateNever
+	part, err := d.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("Error in yenc.RoundTrip: decode err='%w'", err)
+	}
+	if !bytes.Equal(part.Body, data) {
+		off := 0
+		for off < len(data) && off < len(part.Body) && data[off] == part.Body[off] {
+			off++
+		}
+		return part.Body, fmt.Errorf("Error in yenc.RoundTrip: decoded %d bytes differ from the %d input bytes at offset %d", len(part.Body), len(data), off)
+	}
+	return part.Body, nil
+} // end func RoundTrip
+
+// Encode writes the decoded part back out as yenc.
+// Name, Number, Total, Begin, End and the header size are taken from
+// the part, only Line and Profile are used from opts.
+// if opts.Line is not set the line length of the decoded part is used.
+// pcrc32= / crc32= are computed from Body or Chunks.
+func (p *Part) Encode(w io.Writer, opts *EncodeOptions) error {
+	o := EncodeOptions{Name: p.Name, Line: p.cols}
+	if opts != nil {
+		o.Profile = opts.Profile
+		if opts.Line > 0 {
+			o.Line = opts.Line
+		}
+	}
+	if p.Number > 0 {
+		o.Part, o.Total, o.Begin, o.End = p.Number, p.Total, p.Begin, p.End
+		o.Size = p.HeaderSize
+	} else {
+		o.Size = p.bodyLen()
+	}
+	enc := NewEncoder(w, &o)
+	if _, err := p.WriteTo(enc); err != nil {
+		return fmt.Errorf("Error in yenc.Part.Encode: %w", err)
+	}
+	return enc.Close()
+} // end func p.Encode
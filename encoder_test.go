@@ -0,0 +1,356 @@
+package yenc
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	if _, err := RoundTrip(data, &EncodeOptions{Name: "test.bin"}); err != nil {
+		t.Errorf("expected to round-trip: %v", err)
+	}
+}
+
+func TestEscapeProfileRoundTrip(t *testing.T) {
+	profile := &EscapeProfile{Offset: 17, EscapeOffset: 99, Escape: '#'}
+	data := make([]byte, 1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "dialect.bin", Profile: profile}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	encoded := buf.Bytes()
+	decoder := NewDecoder(nil, encoded, nil, -1)
+	if _, err := decoder.Decode(); err == nil {
+		t.Errorf("expected standard profile to fail on dialect")
+	}
+	if _, err := RoundTrip(data, &EncodeOptions{Name: "dialect.bin", Profile: profile}); err != nil {
+		t.Errorf("expected dialect to round-trip: %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(233))
+	data := make([]byte, 10000)
+	rng.Read(data)
+	// encodes to '.' at the start of every line
+	dots := bytes.Repeat([]byte{0x04}, 1000)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		opts *EncodeOptions
+	}{
+		{"nil opts", data, nil},
+		{"single", data, &EncodeOptions{Name: "rt.bin", Line: 61}},
+		{"dot stuffed", dots, &EncodeOptions{Name: "dots.bin", NNTPDotStuff: true}},
+		{"multipart", data, &EncodeOptions{Name: "rt.bin", Part: 1, Total: 2, Size: 20000}},
+		{"empty", nil, &EncodeOptions{Name: "empty.bin"}},
+	} {
+		decoded, err := RoundTrip(tc.data, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: expected to round-trip: %v", tc.name, err)
+		}
+		if !bytes.Equal(decoded, tc.data) {
+			t.Fatalf("%s: expected decoded bytes to match input", tc.name)
+		}
+	}
+}
+
+func TestEncodeDecodeRandomPayloads(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	payloads := [][]byte{
+		{},
+		{0x00},
+		bytes.Repeat([]byte{0x00}, 1000),
+		bytes.Repeat([]byte{0x0A}, 1000),
+		bytes.Repeat([]byte{0x0D, 0x0A}, 500),
+		// encodes to '='
+		bytes.Repeat([]byte{0x13}, 1000),
+		// encodes to '\t', ' ' and '.' which are escaped at the line edges
+		bytes.Repeat([]byte{0xDF, 0xF6, 0x04}, 400),
+	}
+	for _, size := range []int{1, 2, 127, 128, 129, 255, 256, 1000, 4096, 65537} {
+		data := make([]byte, size)
+		rng.Read(data)
+		payloads = append(payloads, data)
+	}
+	for i := 0; i < 50; i++ {
+		data := make([]byte, rng.Intn(8192)+1)
+		rng.Read(data)
+		payloads = append(payloads, data)
+	}
+	for i, data := range payloads {
+		for _, line := range []int{0, 1, 2, 61, 128, 997} {
+			// the decoder checks the crc32= written by the encoder
+			if _, err := RoundTrip(data, &EncodeOptions{Name: "random.bin", Line: line}); err != nil {
+				t.Fatalf("payload %d line %d size %d: expected to round-trip: %v", i, line, len(data), err)
+			}
+		}
+	}
+}
+
+func TestEncoderStreaming(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	data := make([]byte, 5*1024*1024+13)
+	rng.Read(data)
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, &EncodeOptions{Name: "stream.bin", Size: int64(len(data))})
+	// stream in odd sized chunks
+	for rest := data; len(rest) > 0; {
+		n := min(rng.Intn(70000)+1, len(rest))
+		if _, err := enc.Write(rest[:n]); err != nil {
+			t.Fatalf("expected to write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("expected to close: %v", err)
+	}
+	part, err := NewDecoder(&buf, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err)
+	}
+	if !bytes.Equal(part.Body, data) {
+		t.Errorf("expected decoded body to match streamed input")
+	}
+	// size unknown up front
+	buf.Reset()
+	enc = NewEncoder(&buf, &EncodeOptions{Name: "unknown.bin"})
+	enc.Write(data[:1000])
+	if err := enc.Close(); err != nil {
+		t.Fatalf("expected to close: %v", err)
+	}
+	if part, err = NewDecoder(&buf, nil, nil, -1).Decode(); err != nil || !bytes.Equal(part.Body, data[:1000]) {
+		t.Errorf("expected to decode without header size: %v", err)
+	}
+}
+
+func TestPartEncodeRoundTrip(t *testing.T) {
+	for _, file := range []string{"singlepart_test.yenc", "multipart_test.yenc", "multipart_full_test.yenc"} {
+		data := loadFixture(t, file)
+		parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode %s: %v", file, err)
+		}
+		var buf bytes.Buffer
+		for _, part := range parts {
+			if err := part.Encode(&buf, nil); err != nil {
+				t.Fatalf("expected to encode %s: %v", file, err)
+			}
+		}
+		again, err := NewDecoder(&buf, nil, nil, -1).DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode re-encoded %s: %v", file, err)
+		}
+		if len(again) != len(parts) {
+			t.Fatalf("expected %d parts from re-encoded %s got %d", len(parts), file, len(again))
+		}
+		for i := range parts {
+			a, b := parts[i], again[i]
+			if a.Name != b.Name || a.Number != b.Number || a.Total != b.Total || a.Begin != b.Begin || a.End != b.End ||
+				a.HeaderSize != b.HeaderSize || a.Size != b.Size || a.Crc32 != b.Crc32 || !bytes.Equal(a.Body, b.Body) {
+				t.Errorf("expected re-encoded part %d of %s to decode identically", i, file)
+			}
+		}
+	}
+}
+
+func TestSplitForPosting(t *testing.T) {
+	const target = 50000
+	rng := rand.New(rand.NewSource(139))
+	random := make([]byte, 300000)
+	rng.Read(random)
+	// every byte needs an escape: all parts are about half as big
+	escapes := bytes.Repeat([]byte{214, 224, 227, 19}, 30000)
+	name := string(bytes.Repeat([]byte("n"), MaxPostingName))
+	for _, data := range [][]byte{random, escapes} {
+		specs := SplitForPosting(data, target)
+		if len(specs) < 2 {
+			t.Fatalf("expected several parts got %d", len(specs))
+		}
+		var parts []*Part
+		for i, spec := range specs {
+			if spec.Part != i+1 || spec.Total != len(specs) {
+				t.Errorf("unexpected numbering %+v", spec)
+			}
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, &EncodeOptions{Name: name, Size: int64(len(data)), Part: spec.Part, Total: spec.Total, Begin: spec.Begin, End: spec.End})
+			enc.Write(data[spec.Begin-1 : spec.End])
+			if err := enc.Close(); err != nil {
+				t.Fatalf("expected to encode part %d: %v", spec.Part, err)
+			}
+			if buf.Len() > target {
+				t.Errorf("part %d encoded to %d bytes, more than %d", spec.Part, buf.Len(), target)
+			}
+			// the estimate should not waste much room either
+			if i < len(specs)-1 && buf.Len() < target*9/10 {
+				t.Errorf("part %d encoded to only %d bytes of %d", spec.Part, buf.Len(), target)
+			}
+			part, err := NewDecoder(&buf, nil, nil, -1).Decode()
+			if err != nil {
+				t.Fatalf("expected to decode part %d: %v", spec.Part, err)
+			}
+			parts = append(parts, part)
+		}
+		if err := CheckContiguous(parts); err != nil || parts[len(parts)-1].End != int64(len(data)) {
+			t.Errorf("expected parts to cover the data got %v", err)
+		}
+	}
+	if specs := SplitForPosting(random, 300); specs != nil {
+		t.Errorf("expected nil for a target smaller than the headers got %d parts", len(specs))
+	}
+}
+
+func TestEstimateDecodedSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(154))
+	for _, line := range []int{0, 128, 256} {
+		data := make([]byte, 500000)
+		rng.Read(data)
+		var buf bytes.Buffer
+		if err := Encode(&buf, data, &EncodeOptions{Name: "random.bin", Line: line}); err != nil {
+			t.Fatalf("expected to encode: %v", err)
+		}
+		est := EstimateDecodedSize(buf.Len(), line)
+		if diff := est - int64(len(data)); diff < -int64(len(data))/100 || diff > int64(len(data))/100 {
+			t.Errorf("line=%d: estimate %d is more than 1%% off %d", line, est, len(data))
+		}
+	}
+	if est := EstimateDecodedSize(0, 128); est != 0 {
+		t.Errorf("expected 0 for no input got %d", est)
+	}
+}
+
+func TestPartOverhead(t *testing.T) {
+	rng := rand.New(rand.NewSource(173))
+	random := make([]byte, 100000)
+	rng.Read(random)
+	for _, tc := range []struct {
+		data     []byte
+		min, max float64
+	}{
+		{random, 0.01, 0.03},
+		// every byte needs an escape
+		{bytes.Repeat([]byte{214, 224, 227, 19}, 1000), 1, 1},
+		{nil, 0, 0},
+	} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, tc.data, &EncodeOptions{Name: "overhead.bin"}); err != nil {
+			t.Fatalf("expected to encode: %v", err)
+		}
+		part, err := NewDecoder(&buf, nil, nil, -1).Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if o := part.Overhead(); o < tc.min || o > tc.max {
+			t.Errorf("expected overhead between %v and %v got %v (raw %d size %d)", tc.min, tc.max, o, part.RawSize(), part.Size)
+		}
+	}
+}
+
+func TestEncodeNNTPDotStuff(t *testing.T) {
+	// '.' - 42 starts every line with a dot
+	data := bytes.Repeat([]byte{'.' - 42}, 1000)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "dots.bin", NNTPDotStuff: true}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if lines[1][:2] != ".." || lines[len(lines)-1] != "." {
+		t.Fatalf("expected stuffed lines and a lone dot got %q and %q", lines[1], lines[len(lines)-1])
+	}
+	// what the server hands out after reading the article
+	var unstuffed []string
+	for _, line := range lines[:len(lines)-1] {
+		unstuffed = append(unstuffed, strings.TrimPrefix(line, "."))
+	}
+	part, err := NewDecoder(strings.NewReader(strings.Join(unstuffed, "\r\n")+"\r\n"), nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !bytes.Equal(part.Body, data) {
+		t.Errorf("expected unstuffed output to decode to the input")
+	}
+	// or decoded as it is with Decoder.NNTP
+	if _, err := RoundTrip(data, &EncodeOptions{Name: "dots.bin", NNTPDotStuff: true}); err != nil {
+		t.Errorf("expected NNTP decode to match the input: %v", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	for _, fn := range []string{"singlepart_test.yenc", "multipart_full_test.yenc"} {
+		data := loadFixture(t, fn)
+		want, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		for _, width := range []int{2, 3, 61, 200} {
+			out, err := Rewrap(data, width)
+			if err != nil {
+				t.Fatalf("%s: expected to rewrap to %d: %v", fn, width, err)
+			}
+			for _, line := range strings.Split(string(out), "\r\n") {
+				if strings.HasPrefix(line, "=y") {
+					continue
+				}
+				// an escape at the end may exceed the width by one
+				if len(line) > width+1 {
+					t.Fatalf("%s: line of %d chars wrapped to %d", fn, len(line), width)
+				}
+				// "=" is never the second char of an escape pair
+				if strings.HasSuffix(line, "=") {
+					t.Fatalf("%s: escape split at the end of %q", fn, line)
+				}
+			}
+			parts, err := NewDecoder(nil, out, nil, -1).DecodeAll()
+			if err != nil {
+				t.Fatalf("%s: expected to decode rewrapped to %d: %v", fn, width, err.Error())
+			}
+			for i := range parts {
+				if !bytes.Equal(parts[i].Body, want[i].Body) || parts[i].cols != width {
+					t.Errorf("%s: part %d differs rewrapped to %d", fn, i+1, width)
+				}
+			}
+		}
+	}
+	if _, err := Rewrap([]byte("=ybegin line=128 size=1 name=x\r\nabc=\r\n=yend size=1\r\n"), 64); err == nil {
+		t.Error("expected a dangling escape to fail")
+	}
+}
+
+func TestDecodeBodyContainingYend(t *testing.T) {
+	// decoded data which looks like trailers and headers at line starts:
+	// on the encoded side '=' only introduces escapes and the trailer
+	// check runs on the raw line, so none of it can end the body
+	var data []byte
+	for i := 0; i < 200; i++ {
+		data = append(data, "=yend size=1 crc32=00000000\r\n=ybegin line=128 size=1 name=x\r\n"[i%13:]...)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "yend.bin"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("\n=y")); n != 1 {
+		t.Fatalf("expected only the trailer to start with =y got %d lines", n)
+	}
+	lines := fixtureLines(buf.Bytes())
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, buf.Bytes(), nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if !bytes.Equal(part.Body, data) || !bytes.Contains(part.Body, []byte("\r\n=yend")) {
+			t.Errorf("expected the decoded body with =yend inside, got %d of %d bytes", len(part.Body), len(data))
+		}
+	}
+}
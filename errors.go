@@ -0,0 +1,157 @@
+package yenc
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// returned (wrapped) by validate when the body size does not match
+	// the trailer size but the crc32 does.
+	// some broken encoders write the encoded length to =yend size=
+	ErrSizeEncoded = errors.New("yenc: size mismatch but crc32 ok")
+
+	// returned (wrapped) when a numeric field in =ybegin, =ypart or =yend
+	// can not be parsed. the error message contains the offending field.
+	ErrMalformedHeader = errors.New("yenc: malformed header")
+
+	// returned (wrapped) as soon as a body decodes to more bytes
+	// than announced by =ybegin size= or =ypart begin= end=
+	ErrSizeExceeded = errors.New("yenc: decoded size exceeds header size")
+
+	// returned (wrapped) by Decode, DecodeSlice, DecodeAll, DecodeN
+	// and VerifyOne when no yenc part was found in the input.
+	// parts read over because they are not in WantParts do not count
+	ErrNotYEnc = errors.New("yenc: no yenc parts found")
+
+	// returned (wrapped) by readHeader when =ybegin has no size=
+	ErrMissingSize = errors.New("yenc: no size in =ybegin")
+
+	// returned (wrapped) by validate when the body is shorter than
+	// =yend size=: the article was cut off before the declared end
+	ErrTruncatedBeforeTrailer = errors.New("yenc: body truncated before trailer")
+
+	// returned (wrapped) when =ybegin has no or an empty name=
+	ErrMissingName = errors.New("yenc: empty Name field")
+
+	// returned (wrapped) by readPartHeader when a multipart
+	// =ybegin part= is not followed by =ypart
+	ErrMissingPartHeader = errors.New("yenc: no =ypart in multipart")
+
+	// returned (wrapped) when a later part of a multipart file
+	// has another =ybegin total= than the first one
+	ErrInconsistentTotal = errors.New("yenc: inconsistent total in multipart")
+
+	// returned (wrapped) by DecodeExpectingSize when the header
+	// or trailer announces another size than expected
+	ErrSizeMismatch = errors.New("yenc: size differs from expected size")
+
+	// returned (wrapped) with Decoder.VerifyNoExtra if another
+	// =ybegin follows the toCheck parts
+	ErrUnexpectedExtraPart = errors.New("yenc: unexpected extra part")
+
+	// returned (wrapped) by Part.VerifyEncodedSize
+	ErrEncodedSizeMismatch = errors.New("yenc: encoded size differs from expected size")
+
+	// returned (wrapped) when the crc32 of the decoded data does not
+	// match pcrc32= or crc32= (or the crc given to VerifyAgainst)
+	ErrCRCMismatch = errors.New("yenc: crc32 mismatch")
+
+	// returned (wrapped) by validate when the trailer has no crc
+	ErrMissingCRC = errors.New("yenc: no crc in trailer")
+
+	// returned (wrapped in a DecodeError) if a line could not
+	// be read within Decoder.ReadTimeout
+	ErrReadTimeout = errors.New("yenc: read timeout")
+
+	// returned (wrapped in a DecodeError) if the last body line
+	// ends with an escape character and the escaped byte is missing
+	ErrTruncatedEscape = errors.New("yenc: truncated escape at end of body")
+
+	// returned (wrapped in a DecodeError) if a part has more
+	// escapes than allowed by Decoder.MaxEscapeRatio
+	ErrTooManyEscapes = errors.New("yenc: too many escapes")
+
+	// returned (wrapped in a DecodeError) if a line
+	// is longer than Decoder.MaxLineLength
+	ErrLineTooLong = errors.New("yenc: line too long")
+
+	// returned (wrapped) by the decode calls of a decoder without
+	// input, e.g. one from DecoderPool.Get before SetReader
+	ErrNoInput = errors.New("yenc: decoder has no input")
+
+	// returned (wrapped) by the Part helpers which read the
+	// decoded data if it was passed to Decoder.ChunkFunc instead
+	ErrBodyStreamed = errors.New("yenc: body streamed to ChunkFunc")
+
+	// returned (wrapped) by CheckContiguous
+	ErrGap          = errors.New("yenc: gap between parts")
+	ErrOverlap      = errors.New("yenc: parts overlap")
+	ErrInvalidRange = errors.New("yenc: invalid part range")
+)
+
+func malformedHeader(line string, key string, value string, err error) error {
+	return fmt.Errorf("%w: %s %s=%q: %v", ErrMalformedHeader, line, key, value, err)
+}
+
+// DecodeError is returned by readBody and carries the line
+// on which decoding the body failed.
+// Line is the 1-based line number in the reader
+// or the index into the supplied []*string.
+type DecodeError struct {
+	Line int
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v at line %d", e.Err, e.Line)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrPartOutOfOrder is returned when the =yend part= does not
+// match the part number from =ybegin.
+type ErrPartOutOfOrder struct {
+	Expected int
+	Got      int
+}
+
+func (e *ErrPartOutOfOrder) Error() string {
+	return fmt.Sprintf("yenc: =yend header out of order expected part %d got %d", e.Expected, e.Got)
+}
+
+// ErrDuplicateOffset is returned by CheckContiguous when
+// two parts claim the same begin offset.
+type ErrDuplicateOffset struct {
+	Part1 int
+	Part2 int
+	Begin int64
+}
+
+func (e *ErrDuplicateOffset) Error() string {
+	return fmt.Sprintf("yenc: parts %d and %d both begin at %d", e.Part1, e.Part2, e.Begin)
+}
+
+// ErrNameMismatch is returned by DecodeSegment when the
+// decoded name= is not the one expected.
+type ErrNameMismatch struct {
+	Expected string
+	Got      string
+}
+
+func (e *ErrNameMismatch) Error() string {
+	return fmt.Sprintf("yenc: expected name %q got %q", e.Expected, e.Got)
+}
+
+// ErrPartMismatch is returned by DecodeSegment when the
+// decoded part number is not the one expected.
+type ErrPartMismatch struct {
+	Expected int
+	Got      int
+}
+
+func (e *ErrPartMismatch) Error() string {
+	return fmt.Sprintf("yenc: expected part %d got %d", e.Expected, e.Got)
+}
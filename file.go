@@ -0,0 +1,52 @@
+package yenc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DecodeToFile decodes the first part in r and writes its body to
+// path: the data goes to a temporary file next to it which is synced
+// and renamed to path only once the part validated, so path never
+// holds a partial or corrupt body, and which is removed on any error.
+// if path is a directory the file is created in it as SafeName.
+// the file gets mode 0644, not the 0600 of the temporary file.
+// the returned part has no Body, it is in the file.
+func DecodeToFile(r io.Reader, path string) (*Part, error) {
+	dir, isDir := filepath.Dir(path), false
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		dir, isDir = path, true
+	}
+	tmp, err := os.CreateTemp(dir, ".yenc-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("Error in yenc.DecodeToFile: err='%w'", err)
+	}
+	fail := func(err error) (*Part, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	part, err := NewDecoder(r, nil, nil, 1).DecodeToBuffered(bufio.NewWriter(tmp), 0)
+	if err != nil {
+		return fail(fmt.Errorf("Error in yenc.DecodeToFile: err='%w'", err))
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return fail(fmt.Errorf("Error in yenc.DecodeToFile: chmod err='%w'", err))
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(fmt.Errorf("Error in yenc.DecodeToFile: sync err='%w'", err))
+	}
+	if err := tmp.Close(); err != nil {
+		return fail(fmt.Errorf("Error in yenc.DecodeToFile: close err='%w'", err))
+	}
+	if isDir {
+		path = filepath.Join(dir, part.SafeName())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fail(fmt.Errorf("Error in yenc.DecodeToFile: rename err='%w'", err))
+	}
+	return part, nil
+} // end func DecodeToFile
@@ -0,0 +1,92 @@
+package yenc
+
+import (
+	"bytes"
+)
+
+// LineEnding is the line terminator found by Decoder.AutoLineEnding
+type LineEnding int
+
+const (
+	// not detected: AutoLineEnding is off or nothing was read yet
+	EndingUnknown LineEnding = iota
+	EndingCRLF
+	EndingLF
+	// old mac style, lines are split on '\r'
+	EndingCR
+	// more than one kind, lines are split on '\n'
+	EndingMixed
+)
+
+func (e LineEnding) String() string {
+	switch e {
+	case EndingCRLF:
+		return "CRLF"
+	case EndingLF:
+		return "LF"
+	case EndingCR:
+		return "CR"
+	case EndingMixed:
+		return "mixed"
+	}
+	return "unknown"
+}
+
+// detectLineEnding counts the line terminators in sample and returns
+// the kind of ending with the separator to split lines on.
+// a '\r' at the end of sample may be the first half of a CRLF
+// and is not counted.
+func detectLineEnding(sample []byte) (LineEnding, byte) {
+	var crlf, lf, cr int
+	for i, c := range sample {
+		switch {
+		case c == '\n' && i > 0 && sample[i-1] == '\r':
+			crlf++
+		case c == '\n':
+			lf++
+		case c == '\r' && i+1 < len(sample) && sample[i+1] != '\n':
+			cr++
+		}
+	}
+	kinds := 0
+	for _, n := range []int{crlf, lf, cr} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	switch {
+	case kinds > 1:
+		return EndingMixed, '\n'
+	case crlf > 0:
+		return EndingCRLF, '\n'
+	case lf > 0:
+		return EndingLF, '\n'
+	case cr > 0:
+		return EndingCR, '\r'
+	}
+	return EndingUnknown, 0
+}
+
+// detectEnding sets the line separator from the first chunk read
+// from Buf, see AutoLineEnding. only what a single read returns is
+// looked at: a network reader is never waited on for more.
+func (d *Decoder) detectEnding() {
+	if !d.AutoLineEnding || d.Buf == nil || d.sep != 0 {
+		return
+	}
+	if d.Buf.Buffered() == 0 {
+		d.Buf.Peek(1)
+	}
+	sample, _ := d.Buf.Peek(d.Buf.Buffered())
+	if i := bytes.LastIndexAny(sample, "\r\n"); i >= 0 && i+1 < len(sample) {
+		// only complete lines
+		sample = sample[:i+1]
+	}
+	d.ending, d.sep = detectLineEnding(sample)
+}
+
+// LineEnding returns the line terminator detected with AutoLineEnding.
+// EndingUnknown if AutoLineEnding is off or the input is lines.
+func (d *Decoder) LineEnding() LineEnding {
+	return d.ending
+} // end func d.LineEnding
@@ -0,0 +1,82 @@
+package yenc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// errMmapUnsupported is returned by mmapFile where mmap is not available
+var errMmapUnsupported = errors.New("yenc: mmap not supported")
+
+// ReconstructToMmap creates the file at path with totalSize bytes and
+// copies the body of every part to offset Begin-1 (0 for a single part
+// file) through a shared memory mapping of the file, which avoids a
+// write syscall per part when assembling multi-gigabyte files.
+// where mmap is not available the bodies are written with WriteAt.
+// parts may come in any order, every part must fit into totalSize
+// and have End-Begin+1 decoded bytes. see Assembler to write parts
+// as they arrive.
+func ReconstructToMmap(path string, totalSize int64, parts []*Part) error {
+	return reconstruct(path, totalSize, parts, true)
+} // end func ReconstructToMmap
+
+// reconstruct is ReconstructToMmap, with useMmap false
+// it always writes with WriteAt.
+func reconstruct(path string, totalSize int64, parts []*Part, useMmap bool) error {
+	if totalSize < 0 {
+		return fmt.Errorf("Error in yenc.ReconstructToMmap: %w: size %d", ErrInvalidRange, totalSize)
+	}
+	offsets := make([]int64, len(parts))
+	for i, p := range parts {
+		off, n := p.Begin-1, p.bodyLen()
+		if p.streamed > 0 {
+			return fmt.Errorf("Error in yenc.ReconstructToMmap: part %d: %w", p.Number, ErrBodyStreamed)
+		}
+		if p.Number == 0 && p.Begin == 0 {
+			// single part file
+			off = 0
+		} else if p.Begin < 1 || p.End < p.Begin || n != p.End-p.Begin+1 {
+			return fmt.Errorf("Error in yenc.ReconstructToMmap: %w: part %d has begin=%d end=%d and %d bytes", ErrInvalidRange, p.Number, p.Begin, p.End, n)
+		}
+		if off+n > totalSize {
+			return fmt.Errorf("Error in yenc.ReconstructToMmap: %w: part %d ends at %d beyond size %d", ErrInvalidRange, p.Number, off+n, totalSize)
+		}
+		offsets[i] = off
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Error in yenc.ReconstructToMmap: err='%w'", err)
+	}
+	if err := f.Truncate(totalSize); err != nil {
+		f.Close()
+		return fmt.Errorf("Error in yenc.ReconstructToMmap: err='%w'", err)
+	}
+	if useMmap && totalSize > 0 && totalSize <= math.MaxInt {
+		mapped, unmap, err := mmapFile(f, totalSize)
+		if err == nil {
+			for i, p := range parts {
+				off := offsets[i]
+				p.ForEachChunk(func(b []byte) error {
+					off += int64(copy(mapped[off:], b))
+					return nil
+				})
+			}
+			if err := unmap(); err != nil {
+				f.Close()
+				return fmt.Errorf("Error in yenc.ReconstructToMmap: munmap err='%w'", err)
+			}
+			return f.Close()
+		}
+		// fall back to WriteAt
+	}
+	for i, p := range parts {
+		if _, err := p.WriteTo(io.NewOffsetWriter(f, offsets[i])); err != nil {
+			f.Close()
+			return fmt.Errorf("Error in yenc.ReconstructToMmap: part %d: err='%w'", p.Number, err)
+		}
+	}
+	return f.Close()
+} // end func reconstruct
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package yenc
+
+import (
+	"os"
+)
+
+// mmapFile is not available here, ReconstructToMmap uses WriteAt.
+func mmapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
@@ -0,0 +1,92 @@
+package yenc
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// noName is returned by SafeName for a name with no usable characters.
+const noName = "unnamed.bin"
+
+// nameElems splits name on both '/' and '\' and returns the
+// elements without control characters, dropping "", "." and "..".
+func nameElems(name string) []string {
+	var elems []string
+	for _, e := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		e = strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f {
+				return -1
+			}
+			return r
+		}, e)
+		e = strings.TrimSpace(e)
+		if e == "" || e == "." || e == ".." {
+			continue
+		}
+		elems = append(elems, e)
+	}
+	return elems
+}
+
+// SafeName returns the last path element of Name without control
+// characters, safe to create inside a target directory whatever
+// separators the poster used. Name itself is not modified.
+func (p *Part) SafeName() string {
+	elems := nameElems(p.Name)
+	if len(elems) == 0 {
+		return noName
+	}
+	return elems[len(elems)-1]
+} // end func p.SafeName
+
+// NormalizedName returns Name as a relative path using the separator
+// of the host: '/' and '\' both separate elements and every element is
+// cleaned up like in SafeName, so the path can not leave the target directory.
+func (p *Part) NormalizedName() string {
+	elems := nameElems(p.Name)
+	if len(elems) == 0 {
+		return noName
+	}
+	return strings.Join(elems, string(filepath.Separator))
+} // end func p.NormalizedName
+
+// magics are the leading bytes of the file types whose extension
+// Decoder.SniffContentType checks.
+var magics = map[string][]string{
+	".jpg":  {"\xff\xd8\xff"},
+	".jpeg": {"\xff\xd8\xff"},
+	".png":  {"\x89PNG\r\n\x1a\n"},
+	".gif":  {"GIF87a", "GIF89a"},
+	".rar":  {"Rar!\x1a\x07"},
+	".zip":  {"PK\x03\x04", "PK\x05\x06"},
+	".7z":   {"7z\xbc\xaf\x27\x1c"},
+	".par2": {"PAR2\x00PKT"},
+	".pdf":  {"%PDF-"},
+	".gz":   {"\x1f\x8b"},
+	".mkv":  {"\x1a\x45\xdf\xa3"},
+}
+
+// typeMismatch returns true if the name has one of the extensions in
+// magics but the decoded data does not start with the magic bytes of
+// that type, e.g. a .jpg which is a RAR. other extensions and parts
+// which do not hold the start of the file are never a mismatch.
+func (p *Part) typeMismatch() bool {
+	if p.Number > 1 || p.Begin > 1 {
+		return false
+	}
+	want, ok := magics[strings.ToLower(filepath.Ext(p.SafeName()))]
+	if !ok {
+		return false
+	}
+	head := p.Body
+	if len(p.Chunks) > 0 {
+		head = p.Chunks[0]
+	}
+	for _, magic := range want {
+		if bytes.HasPrefix(head, []byte(magic)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package yenc
+
+import (
+	"sync"
+)
+
+// DecoderPool recycles decoders and their read buffers, e.g. in a
+// server decoding many articles concurrently. the zero value is ready
+// to use and safe for concurrent use. the Debug flags are package-wide
+// and apply to pooled decoders like to any other.
+type DecoderPool struct {
+	pool sync.Pool
+}
+
+// Get returns a decoder with no input and default options like
+// NewDecoder(nil, nil, nil, -1): point it at the input with
+// SetReader, SetBytes or SetLines.
+func (p *DecoderPool) Get() *Decoder {
+	if d, ok := p.pool.Get().(*Decoder); ok {
+		return d
+	}
+	return &Decoder{toCheck: -1}
+} // end func p.Get
+
+// Put resets d, which came from Get, and returns it to the pool.
+// the state, input and options of d are all cleared, only its read
+// buffer is kept for the next input. d must not be used by the caller
+// afterward, the parts it returned stay valid.
+func (p *DecoderPool) Put(d *Decoder) {
+	if d == nil {
+		return
+	}
+	d.Reset()
+	buf := d.Buf
+	if buf != nil {
+		// do not keep the old input alive
+		buf.Reset(nil)
+	}
+	*d = Decoder{toCheck: -1, spareBuf: buf}
+	p.pool.Put(d)
+} // end func p.Put
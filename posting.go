@@ -0,0 +1,93 @@
+package yenc
+
+import (
+	"fmt"
+)
+
+// MaxPostingName is the length of the name= value SplitForPosting
+// leaves room for in the =ybegin header of every part.
+const MaxPostingName = 256
+
+// PartSpec is the range of one part as computed by SplitForPosting.
+// Begin and End are 1-based and inclusive like =ypart begin= end=.
+type PartSpec struct {
+	Part, Total int
+	Begin, End  int64
+}
+
+// SplitForPosting splits data into parts so that every part encoded
+// with DefaultLine and StandardProfile, including =ybegin, =ypart and
+// =yend lines and CRLF line endings, is at most targetArticleBytes long.
+// instead of guessing the 2-3% yenc overhead the encoded size is counted
+// from the data itself: bytes which may need an escape are counted twice.
+// returns nil if data is empty or targetArticleBytes is too small
+// to hold the headers and at least one byte.
+func SplitForPosting(data []byte, targetArticleBytes int) []PartSpec {
+	n := int64(len(data))
+	if n == 0 {
+		return nil
+	}
+	// headers and trailer with the widest numbers the parts can have
+	headers := fmt.Sprintf("=ybegin part=%d total=%d line=%d size=%d name=\r\n=ypart begin=%d end=%d\r\n=yend size=%d part=%d pcrc32=00000000\r\n",
+		n, n, DefaultLine, n, n, n, n, n)
+	budget := targetArticleBytes - len(headers) - MaxPostingName
+	var specs []PartSpec
+	for begin := int64(0); begin < n; {
+		i := begin
+		out, col := 0, 0
+		for ; i < n; i++ {
+			w := encodedWidth(data[i])
+			ncol, nout := col+w, out+w
+			if ncol >= DefaultLine {
+				nout += 2
+				ncol = 0
+			}
+			total := nout
+			if ncol > 0 {
+				// the last line needs its CRLF too
+				total += 2
+			}
+			if total > budget {
+				break
+			}
+			out, col = nout, ncol
+		}
+		if i == begin {
+			return nil
+		}
+		specs = append(specs, PartSpec{Part: len(specs) + 1, Begin: begin + 1, End: i})
+		begin = i
+	}
+	for i := range specs {
+		specs[i].Total = len(specs)
+	}
+	return specs
+} // end func SplitForPosting
+
+// encodedWidth returns the worst case number of bytes b is encoded to.
+// whitespace and dots are only escaped at some columns but are
+// always counted as escaped so the estimate never comes out short.
+func encodedWidth(b byte) int {
+	switch b + StandardProfile.Offset {
+	case 0x00, '\n', '\r', '=', '\t', ' ', '.':
+		return 2
+	}
+	return 1
+}
+
+// EstimateDecodedSize estimates the decoded size of encodedLen bytes of
+// yenc body with line length line (<= 0 means DefaultLine): CRLF line
+// endings and about 2% escapes on random data are subtracted.
+// it is meant for buffer sizing and progress only: the exact size
+// is in =ybegin size= (or =ypart begin= end=) once the header is read.
+func EstimateDecodedSize(encodedLen int, line int) int64 {
+	if encodedLen <= 0 {
+		return 0
+	}
+	if line <= 0 {
+		line = DefaultLine
+	}
+	lines := (int64(encodedLen) + int64(line) + 1) / int64(line+2)
+	chars := int64(encodedLen) - 2*lines
+	return chars * 100 / 102
+} // end func EstimateDecodedSize
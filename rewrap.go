@@ -0,0 +1,114 @@
+package yenc
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Rewrap re-wraps the body lines of the yenc articles in encoded to
+// newLine columns without decoding them, e.g. for a server which rejects
+// long lines. escape pairs are never split, =ybegin line= is updated and
+// all other lines are kept. a space, tab or dot which the new wrapping
+// moves to the start or end of a line is escaped, so the output decodes
+// to the same bytes. lines end with CRLF. only StandardProfile is supported.
+func Rewrap(encoded []byte, newLine int) ([]byte, error) {
+	if newLine < 2 {
+		return nil, fmt.Errorf("Error in yenc.Rewrap: line length %d too short", newLine)
+	}
+	var out bytes.Buffer
+	out.Grow(len(encoded) + len(encoded)/newLine*2)
+	var body []byte
+	inBody := false
+	lines := bytes.SplitAfter(encoded, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		// after the final newline
+		lines = lines[:len(lines)-1]
+	}
+	for n, line := range lines {
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case bytes.HasPrefix(line, []byte("=ybegin ")):
+			inBody, body = true, body[:0]
+			out.Write(rewrapBegin(line, newLine))
+			out.WriteString("\r\n")
+		case inBody && bytes.HasPrefix(line, []byte("=ypart ")):
+			out.Write(line)
+			out.WriteString("\r\n")
+		case inBody && bytes.HasPrefix(line, []byte("=yend")):
+			if err := rewrapBody(&out, body, newLine); err != nil {
+				return nil, fmt.Errorf("Error in yenc.Rewrap: line %d: %w", n+1, err)
+			}
+			inBody = false
+			out.Write(line)
+			out.WriteString("\r\n")
+		case inBody:
+			body = append(body, line...)
+		default:
+			// outside of an article
+			out.Write(line)
+			out.WriteString("\r\n")
+		}
+	}
+	if inBody {
+		return nil, fmt.Errorf("Error in yenc.Rewrap: no =yend after =ybegin")
+	}
+	return out.Bytes(), nil
+} // end func Rewrap
+
+// rewrapBegin returns the =ybegin line with line= set to newLine.
+// name= is the last field and may contain spaces: it is kept as it is.
+func rewrapBegin(line []byte, newLine int) []byte {
+	head, name, hasName := bytes.Cut(line, []byte(" name="))
+	fields := bytes.Split(head, []byte(" "))
+	for i, f := range fields {
+		if bytes.HasPrefix(f, []byte("line=")) {
+			fields[i] = []byte("line=" + strconv.Itoa(newLine))
+		}
+	}
+	out := bytes.Join(fields, []byte(" "))
+	if hasName {
+		out = append(append(out, " name="...), name...)
+	}
+	return out
+}
+
+// rewrapBody writes the encoded body, whose lines have been joined,
+// in lines of newLine columns to out.
+func rewrapBody(out *bytes.Buffer, body []byte, newLine int) error {
+	esc := StandardProfile.Escape
+	col := 0
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == esc {
+			if i+1 == len(body) {
+				return fmt.Errorf("escape character at the end of the body")
+			}
+			out.WriteByte(c)
+			out.WriteByte(body[i+1])
+			i++
+			col += 2
+		} else {
+			last := i+1 == len(body) || col+1 >= newLine
+			switch {
+			case (c == ' ' || c == '\t') && (col == 0 || last),
+				c == '.' && col == 0:
+				// the raw byte escaped like an encoder would
+				out.WriteByte(esc)
+				out.WriteByte(c + StandardProfile.EscapeOffset)
+				col += 2
+			default:
+				out.WriteByte(c)
+				col++
+			}
+		}
+		if col >= newLine {
+			out.WriteString("\r\n")
+			col = 0
+		}
+	}
+	if col > 0 {
+		out.WriteString("\r\n")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package yenc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SniffLen is the number of bytes IsYEnc inspects at most.
+var SniffLen = 4096
+
+var ybegin = []byte("=ybegin ")
+
+// IsYEnc reports whether one of the lines in the first SniffLen bytes
+// of b starts with a =ybegin marker. it does not decode anything.
+func IsYEnc(b []byte) bool {
+	if len(b) > SniffLen {
+		b = b[:SniffLen]
+	}
+	for len(b) > 0 {
+		if bytes.HasPrefix(b, ybegin) {
+			return true
+		}
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			return false
+		}
+		b = b[i+1:]
+	}
+	return false
+} // end func IsYEnc
+
+// IsLooksLikeUU reports whether one of the lines in the first SniffLen
+// bytes of b is a uuencode "begin <mode> <name>" line, to tell uuencoded
+// blobs from yenc ones (see IsYEnc) before decoding. begin-base64 lines
+// do not count. it does not decode anything.
+func IsLooksLikeUU(b []byte) bool {
+	if len(b) > SniffLen {
+		b = b[:SniffLen]
+	}
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line, b = b[:i], b[i+1:]
+		} else {
+			b = nil
+		}
+		if isUUBegin(bytes.TrimRight(line, "\r")) {
+			return true
+		}
+	}
+	return false
+} // end func IsLooksLikeUU
+
+// isUUBegin returns true for "begin " followed by
+// an octal mode of 3 or 4 digits, a space and a name.
+func isUUBegin(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte("begin "))
+	if !ok {
+		return false
+	}
+	mode, name, ok := bytes.Cut(rest, []byte(" "))
+	if !ok || len(mode) < 3 || len(mode) > 4 || len(bytes.TrimSpace(name)) == 0 {
+		return false
+	}
+	for _, c := range mode {
+		if c < '0' || c > '7' {
+			return false
+		}
+	}
+	return true
+}
+
+// HeaderKind reports whether the =ybegin line is the header of a
+// multipart article (part= or total=), e.g. to route articles before
+// decoding them. ok is false if line is not a =ybegin line with size=.
+// the fields are parsed with ParseHeaders, nothing else is read.
+func HeaderKind(line string) (multipart bool, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "=ybegin ") {
+		return false, false
+	}
+	values := ParseHeaders([]byte(line[len("=ybegin "):]))
+	if _, ok := values["size"]; !ok {
+		return false, false
+	}
+	_, part := values["part"]
+	_, total := values["total"]
+	return part || total, true
+} // end func HeaderKind
+
+// PeekName returns name= of the first =ybegin line in r without
+// decoding anything: an article header block and lines in front of
+// =ybegin are read over but the =ybegin line itself is only peeked at.
+// if r is a *bufio.Reader it can be decoded from that line on with
+// NewDecoderFromBufio afterwards, any other reader is wrapped and
+// bytes up to the buffer size may be read from it. the =ybegin line
+// must fit into the buffer of the bufio.Reader.
+func PeekName(r io.Reader) (string, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	inHeaders, first, partial := false, true, false
+	for {
+		line, err := peekLine(br)
+		if first {
+			inHeaders, first = isHeaderLine(strings.TrimPrefix(string(line), utf8BOM)), false
+		}
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				return "", fmt.Errorf("Error in yenc.PeekName: no =ybegin found")
+			}
+			return "", fmt.Errorf("Error in yenc.PeekName: err='%w'", err)
+		}
+		if inHeaders && !partial {
+			// like the decoder does, see headerBlockLine
+			inHeaders = headerBlockLine(string(line), func() string {
+				next, _ := peekLineAfter(br, len(line), '\n')
+				return string(next)
+			})
+		}
+		switch {
+		case partial:
+		case inHeaders:
+		case bytes.HasPrefix(bytes.TrimPrefix(line, []byte(utf8BOM)), ybegin):
+			if err == bufio.ErrBufferFull {
+				return "", fmt.Errorf("Error in yenc.PeekName: =ybegin line longer than %d bytes", br.Size())
+			}
+			name := headerName(ParseHeaders(bytes.TrimRight(line, "\r\n")))
+			if name == "" {
+				return "", fmt.Errorf("Error in yenc.PeekName: %w", ErrMissingName)
+			}
+			return name, nil
+		}
+		// not the =ybegin line: a line longer than
+		// the buffer is read over in pieces
+		partial = err == bufio.ErrBufferFull
+		br.Discard(len(line))
+	}
+} // end func PeekName
+
+// peekLine returns the next line in br including the newline without
+// reading it. returns bufio.ErrBufferFull with the buffered bytes if
+// the line does not fit, the rest of the input with io.EOF at the end.
+func peekLine(br *bufio.Reader) ([]byte, error) {
+	return peekLineAfter(br, 0, '\n')
+}
+
+// peekLineAfter works like peekLine for the line starting skip
+// bytes after the read position and ending with sep.
+func peekLineAfter(br *bufio.Reader, skip int, sep byte) ([]byte, error) {
+	// what is buffered first: a network reader may block on more
+	b, _ := br.Peek(max(br.Buffered(), skip))
+	for {
+		if len(b) >= skip {
+			if i := bytes.IndexByte(b[skip:], sep); i >= 0 {
+				return b[skip : skip+i+1], nil
+			}
+		}
+		var err error
+		if b, err = br.Peek(len(b) + 1); err != nil {
+			if len(b) < skip {
+				return nil, err
+			}
+			if i := bytes.IndexByte(b[skip:], sep); i >= 0 {
+				return b[skip : skip+i+1], nil
+			}
+			return b[skip:], err
+		}
+	}
+}
+
+// SplitArticles splits r into the raw bytes of its articles, each
+// from a =ybegin line through the next =yend line including the line
+// endings, without decoding. anything between articles is skipped,
+// an article cut off by another =ybegin is dropped.
+// if r ends inside an article the complete ones are returned
+// with io.ErrUnexpectedEOF.
+func SplitArticles(r io.Reader) ([][]byte, error) {
+	br := bufio.NewReader(r)
+	var articles [][]byte
+	var cur []byte
+	inArticle := false
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, ybegin):
+				cur, inArticle = append([]byte(nil), line...), true
+			case inArticle:
+				cur = append(cur, line...)
+				if bytes.HasPrefix(line, []byte("=yend")) {
+					articles = append(articles, cur)
+					cur, inArticle = nil, false
+				}
+			}
+		}
+		if err == io.EOF {
+			if inArticle {
+				return articles, fmt.Errorf("Error in yenc.SplitArticles: %w", io.ErrUnexpectedEOF)
+			}
+			return articles, nil
+		}
+		if err != nil {
+			return articles, err
+		}
+	}
+} // end func SplitArticles
@@ -0,0 +1,115 @@
+package yenc
+
+import (
+	"slices"
+)
+
+// MultipartSummary is the completion status of a multipart file,
+// see Decoder.Summary
+type MultipartSummary struct {
+	Name string
+	// total= from the headers, 0 if unknown
+	Total int
+	// sorted part numbers decoded and missing
+	Present []int
+	Missing []int
+	// decoded bytes of all present parts
+	TotalBytes int64
+	// all parts present, contiguous and of the size from =ybegin size=
+	AllValid bool
+}
+
+// FileParts are the decoded parts of one file, see Decoder.Files
+type FileParts struct {
+	Name string
+	// the parts were posted with =ybegin part=
+	Multipart bool
+	// in the order they were decoded
+	Parts []*Part
+}
+
+// groupFiles groups parts by Name in the order
+// the first part of each file appears in parts.
+func groupFiles(parts []*Part) []FileParts {
+	var files []FileParts
+	index := make(map[string]int)
+	for _, p := range parts {
+		i, ok := index[p.Name]
+		if !ok {
+			i = len(files)
+			index[p.Name] = i
+			files = append(files, FileParts{Name: p.Name, Multipart: p.Number > 0})
+		}
+		files[i].Parts = append(files[i].Parts, p)
+	}
+	return files
+}
+
+// Files returns the decoded parts grouped by file in the order the
+// files first appear in the input, e.g. after DecodeAll over a dump
+// of several concatenated or interleaved files.
+// see PartsByName for a map.
+func (d *Decoder) Files() []FileParts {
+	return groupFiles(d.parts)
+} // end func d.Files
+
+// fileParts returns the decoded parts of the file the
+// last decoded part belongs to.
+func (d *Decoder) fileParts() []*Part {
+	if len(d.parts) == 0 {
+		return nil
+	}
+	name := d.parts[len(d.parts)-1].Name
+	var parts []*Part
+	for _, p := range d.parts {
+		if p.Name == name {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// MissingParts returns the sorted numbers of the parts of the file
+// last decoded which have not been decoded. without total= only
+// the gaps below the highest part number seen can be reported.
+func (d *Decoder) MissingParts() []int {
+	return d.Summary().Missing
+} // end func d.MissingParts
+
+// Summary returns the completion status of the file last decoded.
+// parts which failed validation are never added to the decoder,
+// so every present part has passed its crc check.
+func (d *Decoder) Summary() MultipartSummary {
+	parts := d.fileParts()
+	if len(parts) == 0 {
+		return MultipartSummary{}
+	}
+	s := MultipartSummary{Name: parts[0].Name}
+	seen := make(map[int]bool, len(parts))
+	for _, p := range parts {
+		n := p.Number
+		if n == 0 {
+			// single part file
+			n, s.Total = 1, 1
+		}
+		s.Total = max(s.Total, p.Total)
+		s.TotalBytes += p.bodyLen()
+		if !seen[n] {
+			seen[n] = true
+			s.Present = append(s.Present, n)
+		}
+	}
+	slices.Sort(s.Present)
+	last := s.Total
+	if last == 0 {
+		last = s.Present[len(s.Present)-1]
+	}
+	for n := 1; n <= last; n++ {
+		if !seen[n] {
+			s.Missing = append(s.Missing, n)
+		}
+	}
+	s.AllValid = s.Total > 0 && len(s.Missing) == 0 && s.TotalBytes == parts[0].HeaderSize &&
+		(parts[0].Number == 0 || CheckContiguous(parts) == nil)
+	return s
+} // end func d.Summary
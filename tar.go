@@ -0,0 +1,50 @@
+package yenc
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// WriteTar writes the decoded files in parts to w as a tar archive,
+// one entry per file named after SafeName. the parts of a multipart
+// file are joined with Concat into a single entry, files are written
+// in the order their first part appears in parts. names which
+// SafeName maps to the same entry get a number: "a.txt", "a (1).txt".
+func WriteTar(w io.Writer, parts []*Part) error {
+	tw := tar.NewWriter(w)
+	used := make(map[string]bool)
+	for _, file := range groupFiles(parts) {
+		data, err := Concat(file.Parts)
+		if err != nil {
+			return fmt.Errorf("Error in yenc.WriteTar %q: %w", file.Name, err)
+		}
+		hdr := &tar.Header{
+			Name: uniqueName(file.Parts[0].SafeName(), used),
+			Mode: 0644,
+			Size: int64(len(data)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("Error in yenc.WriteTar %q: %w", file.Name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("Error in yenc.WriteTar %q: %w", file.Name, err)
+		}
+	}
+	return tw.Close()
+} // end func WriteTar
+
+// uniqueName returns name, or name with " (n)" before the extension
+// if it is in used already, and adds the result to used.
+func uniqueName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for n := 1; used[unique]; n++ {
+		unique = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	used[unique] = true
+	return unique
+} // end func uniqueName
@@ -0,0 +1,54 @@
+package yenc
+
+import (
+	"fmt"
+)
+
+// TraceKind is the milestone recorded by a TraceEvent.
+type TraceKind int
+
+const (
+	// =ybegin has been parsed
+	TraceHeader TraceKind = iota + 1
+	// =ypart has been parsed
+	TracePartHeader
+	// the body lines have been decoded
+	TraceBody
+	// =yend has been parsed
+	TraceTrailer
+	// size and crc of the part matched
+	TraceValidated
+	// decoding failed, Msg holds the error
+	TraceError
+)
+
+// TraceEvent is recorded in Decoder.Trace if TraceEnabled is set.
+type TraceEvent struct {
+	Kind TraceKind
+	// the part number from =ybegin, 0 for single part files
+	Part int
+	// lines read so far (the index into []*string input) and bytes
+	// read from the buffered input, 0 for []*string input
+	Line   int
+	Offset int64
+	Msg    string
+}
+
+func (e TraceEvent) String() string {
+	return fmt.Sprintf("kind=%d part=%d line=%d offset=%d %s", e.Kind, e.Part, e.Line, e.Offset, e.Msg)
+}
+
+// trace records an event if tracing is enabled.
+func (d *Decoder) trace(kind TraceKind, format string, a ...any) {
+	if !d.TraceEnabled {
+		return
+	}
+	ev := TraceEvent{Kind: kind, Line: d.line, Offset: d.offset, Msg: fmt.Sprintf(format, a...)}
+	if d.Dat != nil {
+		ev.Line = d.datPos
+	}
+	if d.part != nil {
+		ev.Part = d.part.Number
+	}
+	d.Trace = append(d.Trace, ev)
+}
@@ -0,0 +1,179 @@
+package yenc
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// VerifyDir verifies the files in paths with workers goroutines
+// (< 1 means 1) and returns the result for every path: nil if all
+// yenc parts in the file decode with matching size and crc.
+// files not verified when ctx is cancelled get ctx.Err().
+func VerifyDir(ctx context.Context, paths []string, workers int) map[string]error {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make(map[string]error, len(paths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				err := ctx.Err()
+				if err == nil {
+					err = verifyFile(path)
+				}
+				mu.Lock()
+				results[path] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		select {
+		case work <- path:
+		case <-ctx.Done():
+			mu.Lock()
+			results[path] = ctx.Err()
+			mu.Unlock()
+		}
+	}
+	close(work)
+	wg.Wait()
+	return results
+} // end func VerifyDir
+
+// verifyFile validates all parts in the file at path
+// without keeping them.
+func verifyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := NewDecoder(f, nil, nil, -1)
+	d.verifyOnly = true
+	for n := 0; ; n++ {
+		if err := d.next(); err != nil {
+			if err != io.EOF {
+				return fmt.Errorf("Error in yenc.VerifyDir %s err='%w'", path, err)
+			}
+			if n == 0 {
+				return fmt.Errorf("Error in yenc.VerifyDir %s: %w", path, ErrNotYEnc)
+			}
+			return nil
+		}
+		if d.lastPartSeen() && d.fullcrcSet {
+			if err := d.validate(); err != nil {
+				return fmt.Errorf("Error in yenc.VerifyDir %s err='%w'", path, err)
+			}
+		}
+	}
+} // end func verifyFile
+
+// VerifyPartsParallel checks the pcrc32= of every part with workers
+// goroutines (< 1 means 1) and returns the result for every part at the
+// same index: the crc is computed again from RawLines if the part was
+// decoded with KeepRawLines (with the Profile and ColumnZeroEscaping
+// of that decoder), else from Body or Chunks. every part is hashed on its own,
+// parts must not be modified while this runs.
+func VerifyPartsParallel(parts []*Part, workers int) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				errs[i] = verifyPart(parts[i])
+			}
+		}()
+	}
+	for i := range parts {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return errs
+} // end func VerifyPartsParallel
+
+// verifyPart computes the crc of p again and compares it to Crc32.
+func verifyPart(p *Part) error {
+	if !p.crcSet && p.Crc32 == 0 {
+		return fmt.Errorf("Error in yenc.VerifyPartsParallel: part %d: %w", p.Number, ErrMissingCRC)
+	}
+	h := crc32.NewIEEE()
+	if p.RawLines != nil {
+		// a decoder of its own: decode keeps escape state across lines
+		d := &Decoder{Profile: p.rawProfile}
+		var buf []byte
+		for _, line := range p.RawLines {
+			// decode works in place, RawLines must stay as they are
+			buf = append(buf[:0], line...)
+			if p.rawColumnZero {
+				buf = unstuffColumnZero(buf)
+			}
+			h.Write(d.decode(buf))
+		}
+		if d.awaitingSpecial {
+			return fmt.Errorf("Error in yenc.VerifyPartsParallel: part %d: %w", p.Number, ErrTruncatedEscape)
+		}
+	} else if _, err := p.WriteTo(h); err != nil {
+		return fmt.Errorf("Error in yenc.VerifyPartsParallel: part %d: %w", p.Number, err)
+	}
+	if sum := h.Sum32(); sum != p.Crc32 {
+		return fmt.Errorf("Error in yenc.VerifyPartsParallel: %w: crc check failed for part %d expected %s got %s", ErrCRCMismatch, p.Number, hexCRC(p.Crc32), hexCRC(sum))
+	}
+	return nil
+}
+
+// IntegrityReport is a snapshot of the health of a part,
+// see Part.Integrity.
+type IntegrityReport struct {
+	// the decoded bytes match =yend size= and, for a single part
+	// file, =ybegin size=
+	SizeOK bool
+	// a crc was computed and matches pcrc32= (crc32= if single part).
+	// false if the trailer has none or Decoder.SkipCRC was set
+	CRCOK bool
+	// =ypart begin= end= is a valid range covering the decoded bytes
+	// within =ybegin size=. true for a single part without a range
+	BeginEndOK bool
+	// the crc of the decoded data, 0 with Decoder.SkipCRC
+	ComputedCRC uint32
+	// pcrc32= or crc32= from the trailer, 0 if none
+	ExpectedCRC uint32
+}
+
+// OK returns true if all checks of the report passed.
+func (r IntegrityReport) OK() bool {
+	return r.SizeOK && r.CRCOK && r.BeginEndOK
+}
+
+// Integrity checks size, crc and range of the part at once and
+// reports each result instead of stopping at the first failure.
+// it is computed from the fields when called, nothing is hashed again.
+func (p *Part) Integrity() IntegrityReport {
+	n := p.bodyLen()
+	r := IntegrityReport{ComputedCRC: p.ComputedCRC32(), ExpectedCRC: p.Crc32}
+	r.SizeOK = n == p.Size && (p.Number > 0 || p.HeaderSize == 0 || n == p.HeaderSize)
+	r.CRCOK = p.crcHash != nil && !p.crcSkipped && (p.crcSet || p.Crc32 != 0) && r.ComputedCRC == r.ExpectedCRC
+	if p.Begin == 0 && p.End == 0 {
+		r.BeginEndOK = p.Number == 0
+	} else {
+		r.BeginEndOK = p.Begin >= 1 && p.End >= p.Begin && p.End-p.Begin+1 == n &&
+			(p.HeaderSize == 0 || p.End <= p.HeaderSize)
+	}
+	return r
+} // end func p.Integrity
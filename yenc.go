@@ -6,13 +6,22 @@ package yenc
 import (
 	"bufio"
 	"bytes"
+	"encoding"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
+	"iter"
 	"strconv"
 	"strings"
+	"time"
 	"log"
+	"math"
+	"net"
+	"os"
+	"slices"
 )
 
 var (
@@ -22,6 +31,23 @@ var (
 	DebugThis11 = false
 )
 
+// UppercaseHex formats crc32 values in error and log messages
+// and in the CRCHex helpers as uppercase hex. default lowercase.
+// the encoder always writes lowercase.
+var UppercaseHex = false
+
+// hexCRC returns v as 8-digit hex in the case set by UppercaseHex.
+func hexCRC(v uint32) string {
+	if UppercaseHex {
+		return fmt.Sprintf("%08X", v)
+	}
+	return fmt.Sprintf("%08x", v)
+}
+
+// ParseHeaders splits the fields of a =ybegin, =ypart or =yend line
+// into a map. "name" holds the rest of the line from name= on,
+// including the "name=" prefix like it always did. double quotes
+// around the name are stripped like readHeader does for Part.Name.
 func ParseHeaders(inputBytes []byte) map[string]string {
 	values := make(map[string]string)
 	input := string(inputBytes)
@@ -29,6 +55,9 @@ func ParseHeaders(inputBytes []byte) map[string]string {
 	ni := strings.Index(input, "name=")
 	if ni > -1 {
 		values["name"] = input[ni:]
+		if v := strings.TrimSpace(input[ni+len("name="):]); unquoteName(v) != v {
+			values["name"] = "name=" + unquoteName(v)
+		}
 	} else {
 		ni = len(input)
 	}
@@ -44,9 +73,37 @@ func ParseHeaders(inputBytes []byte) map[string]string {
 	return values
 }
 
+// headerName returns the name from ParseHeaders values like
+// readHeader sets Part.Name: without "name=".
+func headerName(values map[string]string) string {
+	return strings.TrimSpace(strings.TrimPrefix(values["name"], "name="))
+}
+
+// unquoteName strips the double quotes some encoders put around name=
+// and unescapes \" and \\ inside. other names are returned as is.
+func unquoteName(name string) string {
+	if len(name) < 2 || name[0] != '"' || name[len(name)-1] != '"' {
+		return name
+	}
+	name = name[1 : len(name)-1]
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) && (name[i+1] == '"' || name[i+1] == '\\') {
+			i++
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
 type Part struct {
 	// part num
 	Number int
+	// total= from header, 0 if not given
+	Total int
 	// size from header
 	HeaderSize int64
 	// size from part trailer
@@ -55,34 +112,423 @@ type Part struct {
 	Begin, End int64
 	// filename from yenc header
 	Name string
-	// line length of part
+	// line length of part from line=, informational only:
+	// body lines of any length are decoded.
+	// DefaultLine (128) if the header has no line=
 	cols int
 	// crc check for this part
 	Crc32   uint32
 	crcHash hash.Hash32
+	// trailer had pcrc32= or crc32=
+	crcSet bool
 	// the decoded data
 	Body []byte
+	// the decoded data in chunks of Decoder.ChunkSize bytes
+	// instead of Body if ChunkSize is set, see WriteTo
+	Chunks [][]byte
+	// sum of Decoder.ExtraHash over the decoded data, nil if not set
+	ExtraSum []byte
+	// the encoded body lines without line terminators
+	// only if Decoder.KeepRawLines is set. NNTP dot-stuffing and
+	// TrimFunc are applied already, ColumnZeroEscaping is not.
+	RawLines [][]byte
+	// how RawLines decode: Decoder.Profile and ColumnZeroEscaping
+	rawProfile    *EscapeProfile
+	rawColumnZero bool
+	// the =ybegin and =yend lines as read, without line terminator.
+	// a =ybegin wrapped over two lines is joined with a space.
+	RawBegin, RawEnd string
+	// line numbers (as in DecodeError) skipped by Decoder.SkipBadLines
+	BadLines []int
+	// best-effort guess that the body contains a CR or LF which the
+	// encoder did not escape: a full length line was followed by a short
+	// line which was not the last one, as if a line had been split.
+	// such a part usually fails validation.
+	SuspectUnescapedNewline bool
+	// collected while decoding, see DecodeResult
+	stats     Stats
+	warnings  []Warning
+	longLines int
+	// short body lines after a full line followed by another line
+	fragments int
+	// the last line was full length / short after a full one
+	prevFull, pendingShort bool
+	// bytes passed to Decoder.ChunkFunc instead of Body
+	streamed int64
+	// decoded with Decoder.SkipCRC: crcHash is empty
+	crcSkipped bool
+	// length of the =ypart line without line terminator, 0 if none
+	partLineLen int
+	// lines starting with "=y" in the body which are no known
+	// marker, without line terminator. they are not decoded.
+	UnknownMarkers []string
+	// set by Decoder.SniffContentType if the data does not start
+	// with the magic bytes the extension of Name calls for
+	TypeMismatch bool
+	// time spent on header, body and validation with Decoder.Timing,
+	// including the scan for =ybegin. 0 without Timing
+	DecodeDuration time.Duration
+}
+
+// Stats are counted while decoding the body of a part.
+type Stats struct {
+	// number of body lines
+	Lines int
+	// encoded body bytes without line terminators
+	RawBytes int64
+	// number of escape sequences
+	Escapes int
+	// length of the longest encoded line
+	MaxLine int
+}
+
+type WarningKind int
+
+const (
+	// body lines longer than line= from =ybegin
+	WarnLineLength WarningKind = iota + 1
+	// =yend size= does not match =ybegin size= (or =ypart begin= end=)
+	WarnHeaderSize
+	// =yend has no pcrc32= or crc32=
+	WarnMissingCRC
+	// see Part.SuspectUnescapedNewline
+	WarnUnescapedNewline
+	// lines skipped by Decoder.SkipBadLines
+	WarnBadLines
+	// invalid =ybegin lines skipped by Decoder.SkipInvalidHeaders
+	WarnSkippedHeader
+)
+
+// Warning is a non-fatal problem found while decoding a part.
+type Warning struct {
+	Kind WarningKind
+	Part int
+	Msg  string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("part %d: %s", w.Part, w.Msg)
+}
+
+// Result bundles a decoded part with its stats and warnings.
+type Result struct {
+	Part     *Part
+	Stats    Stats
+	Warnings []Warning
+}
+
+func (p *Part) warn(kind WarningKind, format string, a ...any) {
+	p.warnings = append(p.warnings, Warning{Kind: kind, Part: p.Number, Msg: fmt.Sprintf(format, a...)})
 }
 
 func (p *Part) validate() error {
 	// length checks
 	if Debug1 {
-		log.Printf("yenc.Part.validate() p.Number=%d c.Crc32=%x", p.Number, p.Crc32)
+		log.Printf("yenc.Part.validate() p.Number=%d c.Crc32=%s", p.Number, hexCRC(p.Crc32))
 	}
-	if int64(len(p.Body)) != p.Size {
-		return fmt.Errorf("Error in yenc.Part.validate: Body size %d did not match expected size %d", len(p.Body), p.Size)
+	// every discrepancy is reported, joined by errors.Join
+	var errs []error
+	if p.bodyLen() != p.Size {
+		if p.Crc32 > 0 && p.crcHash.Sum32() == p.Crc32 {
+			return fmt.Errorf("Error in yenc.Part.validate: %w: Body size %d did not match expected size %d", ErrSizeEncoded, p.bodyLen(), p.Size)
+		}
+		if p.bodyLen() < p.Size {
+			errs = append(errs, fmt.Errorf("Error in yenc.Part.validate: %w: %d of %d bytes missing", ErrTruncatedBeforeTrailer, p.Size-p.bodyLen(), p.Size))
+		} else {
+			errs = append(errs, fmt.Errorf("Error in yenc.Part.validate: Body size %d did not match expected size %d", p.bodyLen(), p.Size))
+		}
 	}
 	// crc check
-	if p.Crc32 > 0 {
+	if p.crcSkipped {
+		return errors.Join(errs...)
+	}
+	if p.Crc32 > 0 || p.crcSet {
 		if sum := p.crcHash.Sum32(); sum != p.Crc32 {
-			return fmt.Errorf("Error in yenc.Part.validate: crc check failed for part %d expected %x got %x", p.Number, p.Crc32, sum)
+			errs = append(errs, fmt.Errorf("Error in yenc.Part.validate: %w: crc check failed for part %d expected %s got %s", ErrCRCMismatch, p.Number, hexCRC(p.Crc32), hexCRC(sum)))
 		}
-		if Debug1 {
+		if Debug1 && len(errs) == 0 {
 			log.Printf("OK yenc.part.validate() p.Number=%d", p.Number)
 		}
+		return errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		// a missing crc is no discrepancy on its own: Decoder.allowMissingCRC
+		// must not let a part of the wrong size pass
+		return errors.Join(errs...)
+	}
+	if p.Size == 0 {
+		// empty placeholder part: crc32 of no data is 00000000
+		return nil
+	}
+	return fmt.Errorf("Error in yenc.Part.validate: %w", ErrMissingCRC)
+}
+
+// bodyLen returns the number of decoded bytes in Body or Chunks.
+func (p *Part) bodyLen() int64 {
+	if p.streamed > 0 {
+		return p.streamed
+	}
+	if n := len(p.Chunks); n > 0 {
+		// all chunks but the last are full
+		return int64((n-1)*len(p.Chunks[0]) + len(p.Chunks[n-1]))
+	}
+	return int64(len(p.Body))
+}
+
+// appendChunked appends b to Chunks, starting a new chunk
+// of size bytes whenever the last one is full.
+func (p *Part) appendChunked(b []byte, size int) {
+	for len(b) > 0 {
+		n := len(p.Chunks)
+		if n == 0 || len(p.Chunks[n-1]) == size {
+			p.Chunks = append(p.Chunks, make([]byte, 0, size))
+			n++
+		}
+		last := p.Chunks[n-1]
+		k := min(size-len(last), len(b))
+		p.Chunks[n-1] = append(last, b[:k]...)
+		b = b[k:]
+	}
+}
+
+// ForEachChunk calls fn with the decoded data of the part in order:
+// every chunk if the part was decoded with Decoder.ChunkSize, else
+// Body at once. nothing is copied, the slices alias the part and must
+// not be modified or retained. stops at and returns the first error of fn.
+// to consume the data while decoding without storing it at all
+// see Decoder.ChunkFunc: such a part returns ErrBodyStreamed.
+func (p *Part) ForEachChunk(fn func(b []byte) error) error {
+	if p.streamed > 0 {
+		return fmt.Errorf("Error in yenc.Part.ForEachChunk: %w: %d bytes", ErrBodyStreamed, p.streamed)
+	}
+	if p.Chunks == nil {
+		if len(p.Body) == 0 {
+			return nil
+		}
+		return fn(p.Body)
+	}
+	for _, chunk := range p.Chunks {
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+} // end func p.ForEachChunk
+
+// WriteTo writes the decoded data to w, from Chunks
+// if the part was decoded with Decoder.ChunkSize, else from Body.
+func (p *Part) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	err := p.ForEachChunk(func(b []byte) error {
+		n, err := w.Write(b)
+		total += int64(n)
+		return err
+	})
+	return total, err
+}
+
+// CRC32 returns the IEEE crc32 of data
+// as used by yenc for pcrc32= and crc32=
+func CRC32(data []byte) uint32 {
+	return crc32.ChecksumIEEE(data)
+}
+
+// CRC32Hex returns CRC32(data) as 8-digit hex, see UppercaseHex.
+func CRC32Hex(data []byte) string {
+	return hexCRC(CRC32(data))
+}
+
+// ComputedCRC32 returns the crc32 computed over the decoded body.
+// returns 0 if the part has not been decoded.
+func (p *Part) ComputedCRC32() uint32 {
+	if p.crcHash == nil {
+		return 0
+	}
+	return p.crcHash.Sum32()
+}
+
+// CRCHex returns the computed crc32 as 8-digit hex (see UppercaseHex)
+// as it would appear in a yenc trailer.
+func (p *Part) CRCHex() string {
+	return hexCRC(p.ComputedCRC32())
+}
+
+// ExpectedCRCHex returns the crc32 from the part trailer
+// as 8-digit hex, see UppercaseHex.
+func (p *Part) ExpectedCRCHex() string {
+	return hexCRC(p.Crc32)
+}
+
+// VerifyAgainst compares the computed crc32 of the decoded body with
+// crc from another source than the trailer, e.g. an nzb or an index.
+// the body is not hashed again.
+func (p *Part) VerifyAgainst(crc uint32) error {
+	if p.crcHash == nil || p.crcSkipped {
+		return fmt.Errorf("Error in yenc.Part.VerifyAgainst: no crc computed for part %d", p.Number)
+	}
+	if sum := p.crcHash.Sum32(); sum != crc {
+		return fmt.Errorf("Error in yenc.Part.VerifyAgainst: %w: crc check failed for part %d expected %s got %s", ErrCRCMismatch, p.Number, hexCRC(crc), hexCRC(sum))
+	}
+	return nil
+} // end func p.VerifyAgainst
+
+// errDiffer stops VerifyAgainstReader at the first difference
+var errDiffer = errors.New("yenc: differ")
+
+// VerifyAgainstReader compares the decoded data with the bytes read
+// from r without reading all of r into memory, e.g. a re-download or
+// the input of an encoder. returns true and -1 if r holds exactly the
+// same bytes, else false and the offset of the first byte which
+// differs (or where the shorter of the two ends). err is only set if
+// reading r failed.
+func (p *Part) VerifyAgainstReader(r io.Reader) (bool, int64, error) {
+	var off int64
+	ref := make([]byte, 32*1024)
+	err := p.ForEachChunk(func(b []byte) error {
+		for len(b) > 0 {
+			n, err := io.ReadFull(r, ref[:min(len(ref), len(b))])
+			for i := 0; i < n; i++ {
+				if ref[i] != b[i] {
+					off += int64(i)
+					return errDiffer
+				}
+			}
+			off += int64(n)
+			b = b[n:]
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				// r is shorter
+				return errDiffer
+			}
+			if err != nil {
+				return err
+			}
+		}
 		return nil
+	})
+	switch {
+	case err == errDiffer:
+		return false, off, nil
+	case err != nil:
+		return false, off, fmt.Errorf("Error in yenc.Part.VerifyAgainstReader: err='%w'", err)
+	}
+	// r must end here too
+	n, err := io.ReadFull(r, ref[:1])
+	if n > 0 {
+		return false, off, nil
+	}
+	if err != io.EOF {
+		return false, off, fmt.Errorf("Error in yenc.Part.VerifyAgainstReader: err='%w'", err)
+	}
+	return true, -1, nil
+} // end func p.VerifyAgainstReader
+
+// String returns a one line summary of the part for logging.
+// the body itself is not printed.
+func (p *Part) String() string {
+	return fmt.Sprintf("yenc.Part{Number=%d Total=%d Name=%q HeaderSize=%d Size=%d Begin=%d End=%d Crc32=%s computed=%s Body=%d}",
+		p.Number, p.Total, p.Name, p.HeaderSize, p.Size, p.Begin, p.End, p.ExpectedCRCHex(), p.CRCHex(), p.bodyLen())
+}
+
+// LineLength returns line= from =ybegin, DefaultLine if the
+// header has none, e.g. for EstimateDecodedSize or Rewrap.
+func (p *Part) LineLength() int {
+	return p.cols
+}
+
+// RawSize returns the number of encoded body bytes read for the
+// part, without line terminators and without the =ybegin, =ypart
+// and =yend lines.
+func (p *Part) RawSize() int64 {
+	return p.stats.RawBytes
+}
+
+// Overhead returns (RawSize - Size) / Size: the extra encoded bytes
+// per decoded byte, about 0.01 to 0.02 for random data and more for
+// data with many bytes which need escapes. line terminators and
+// headers are not counted. returns 0 for an empty part.
+func (p *Part) Overhead() float64 {
+	if p.Size == 0 {
+		return 0
+	}
+	return float64(p.RawSize()-p.Size) / float64(p.Size)
+}
+
+// EncodedSize returns the size of the part as it was posted: the
+// encoded body and the =ybegin, =ypart and =yend lines, every line
+// counted with a CRLF terminator. this is about what a NZB segment
+// bytes= declares, which may also count the article headers.
+func (p *Part) EncodedSize() int64 {
+	n := p.stats.RawBytes + 2*int64(p.stats.Lines)
+	n += int64(len(p.RawBegin)+2) + int64(len(p.RawEnd)+2)
+	if p.partLineLen > 0 {
+		n += int64(p.partLineLen + 2)
+	}
+	return n
+}
+
+// VerifyEncodedSize returns ErrEncodedSizeMismatch if EncodedSize
+// differs from expected, e.g. a NZB segment bytes=, by more than
+// tolerance times expected (0.05 allows 5%). a wrong or truncated
+// article is usually far off, article headers are not.
+func (p *Part) VerifyEncodedSize(expected int64, tolerance float64) error {
+	got := p.EncodedSize()
+	if diff := math.Abs(float64(got - expected)); diff > tolerance*float64(expected) {
+		return fmt.Errorf("Error in yenc.Part.VerifyEncodedSize: %w: part %d has %d encoded bytes but %d expected (tolerance %g)", ErrEncodedSizeMismatch, p.Number, got, expected, tolerance)
+	}
+	return nil
+}
+
+// CharsetDecoder converts bytes in some charset to UTF-8.
+// *encoding.Decoder from golang.org/x/text/encoding satisfies it,
+// e.g. charmap.Windows1252.NewDecoder()
+type CharsetDecoder interface {
+	Bytes(b []byte) ([]byte, error)
+}
+
+// DecodedName returns the filename converted to UTF-8 using dec.
+// Name holds the raw bytes from the =ybegin header as they are
+// (usenet filenames are often latin-1 or cp1252) and is not touched.
+func (p *Part) DecodedName(dec CharsetDecoder) (string, error) {
+	name, err := dec.Bytes([]byte(p.Name))
+	if err != nil {
+		return "", fmt.Errorf("Error in yenc.Part.DecodedName: err='%w'", err)
+	}
+	return string(name), nil
+}
+
+// BodyString returns the decoded data as a string, joined from
+// Chunks if the part was decoded with Decoder.ChunkSize. yenc carries
+// any bytes: it is up to the caller to know the payload is text,
+// e.g. an NFO file. empty for a part decoded with ChunkFunc, whose
+// data is not held, see ForEachChunk to tell it from an empty body.
+func (p *Part) BodyString() string {
+	if p.Chunks == nil {
+		return string(p.Body)
+	}
+	var sb strings.Builder
+	sb.Grow(int(p.bodyLen()))
+	for _, chunk := range p.Chunks {
+		sb.Write(chunk)
 	}
-	return fmt.Errorf("Error in yenc.Part.validate: p.Crc32 not set")
+	return sb.String()
+}
+
+// BodyStringCharset returns the decoded text converted to UTF-8
+// using dec, for text which is not UTF-8 (NFO files are often cp437).
+// like BodyString the caller must know the payload is text.
+// dec is the CharsetDecoder interface and not an encoding.Encoding
+// of golang.org/x/text so the package needs nothing outside the
+// standard library: pass enc.NewDecoder() of such an encoding.
+// returns ErrBodyStreamed for a part decoded with ChunkFunc.
+func (p *Part) BodyStringCharset(dec CharsetDecoder) (string, error) {
+	if p.streamed > 0 {
+		return "", fmt.Errorf("Error in yenc.Part.BodyStringCharset: %w", ErrBodyStreamed)
+	}
+	text, err := dec.Bytes([]byte(p.BodyString()))
+	if err != nil {
+		return "", fmt.Errorf("Error in yenc.Part.BodyStringCharset: err='%w'", err)
+	}
+	return string(text), nil
 }
 
 type Decoder struct {
@@ -100,24 +546,268 @@ type Decoder struct {
 	parts []*Part
 	// active part
 	part *Part
-	// overall crc check
-	Fullcrc32   uint32
-	crcHash hash.Hash32
+	// crc32= of the file the active part belongs to, kept per file
+	// and cleared when a new file (or its part 1) starts
+	Fullcrc32 uint32
+	crcHash   hash.Hash32
+	// a =yend of that file carried crc32=
+	fullcrcSet bool
+	// input is an NNTP article body: lines are dot-stuffed
+	// and the body ends with a line holding a lone "."
+	// do not combine with ColumnZeroEscaping which would unstuff twice.
+	NNTP bool
+	// NNTP: the lone "." has been read
+	articleEnd bool
+	// size of the read buffer, <= 0 means the bufio default (4096).
+	// set before decoding: applied when the first part is read
+	BufferSize int
+	// strips the line terminator (and whatever else) from every
+	// body line before decoding. the default for the buffered input
+	// is bytes.TrimRight(line, "\r\n"). []*string lines always have
+	// trailing '\r' and '\n' removed first, TrimFunc gets them without.
+	// must return a subslice of or the line itself.
+	TrimFunc func(line []byte) []byte
+	// DecodeResult: a missing crc is a warning, not an error
+	allowMissingCRC bool
+	// when to check the crc32= of the whole file.
+	// the default ValidateAuto checks it when all parts have been seen.
+	ValidateFull ValidateMode
+	// maximum length of a line without its terminator.
+	// 0 means DefaultMaxLineLength, < 0 means no limit.
+	// protects against a stream without line breaks eating all memory.
+	MaxLineLength int
+	// skip decoding of body lines, see DecodeHeaderOnly
+	headerOnly bool
+	// salvage: skip body lines with an unescaped NUL or CR
+	// and record their numbers in Part.BadLines. without it
+	// these lines are decoded like any other.
+	// such a part does not validate but is returned anyway
+	// with a WarnBadLines warning instead of the size/crc error.
+	SkipBadLines bool
+	// the decoded size known in advance, see DecodeExpectingSize
+	expectSize int64
+	// do not record processed parts, see VerifyOne
+	verifyOnly bool
+	// decode only parts with these numbers (single part files
+	// have number 0). the bodies of other parts are read over
+	// without decoding or hashing. nil decodes all parts.
+	WantParts map[int]bool
+	// the active part is not in WantParts
+	skipPart bool
+	// read lines with a bufio.Scanner holding at most ScannerMax bytes
+	// (0 means DefaultMaxLineLength) including the line terminator.
+	// longer lines return ErrLineTooLong, MaxLineLength does not apply.
+	// the scanner reads ahead of Buf, Buffered() takes that back.
+	UseScanner bool
+	ScannerMax int
+	scanner    *bufio.Scanner
+	// bytes the scanner read from Buf but did not return yet
+	scanRead bytes.Buffer
+	// a line scanned by peekRawLine, returned by the next scanLine
+	scanAhead *scannedLine
+	// decoded bytes are also written to ExtraHash (e.g. sha256.New())
+	// which is reset for every part, the sum is in Part.ExtraSum.
+	// saves a second pass over the body. nil for no extra hash.
+	ExtraHash hash.Hash
+	// decode bodies into Part.Chunks of ChunkSize bytes instead of
+	// one contiguous Part.Body. avoids a single huge allocation for
+	// big parts but the data has to be read with Part.WriteTo.
+	// 0 decodes into Body.
+	ChunkSize int
+	// called with the decoded bytes of every body line as they are
+	// produced. the part is neither stored in Body nor in Chunks,
+	// size and crc are still checked. b aliases the read buffer and is
+	// only valid during the call: copy it to keep it.
+	// an error aborts decoding and is returned in a DecodeError.
+	ChunkFunc func(p *Part, b []byte) error
+	// called with the number of decoded bytes of every body line,
+	// e.g. to account throughput or to wait on a rate limiter.
+	// nil for none.
+	OnBytes func(n int)
+	// do not compute any crc32 when it would not be checked anyway:
+	// parts are validated on their size alone and the crc32= of the
+	// whole file is never checked. saves the hashing on large bodies,
+	// ComputedCRC32 of such a part is 0.
+	SkipCRC bool
+	// record the milestones of decoding in Trace, e.g. to see how far
+	// a failed decode got. events are appended, also across Reset.
+	TraceEnabled bool
+	Trace        []TraceEvent
+	// bytes read from Buf, see TraceEvent
+	offset int64
+	// offset of the last =ybegin line read from Buf
+	beginOffset int64
+	// see PeakBytes
+	peakBytes int64
+	// wraps the input once before decoding, e.g. in a zstd or xz
+	// reader for compressed spools, without adding a dependency
+	// to this package. only applies to io.Reader and []byte input
+	// given to NewDecoder, NewDecoderAt or SetReader.
+	Decompress func(r io.Reader) (io.Reader, error)
+	// the whole input is base64 (standard encoding, line breaks are
+	// ignored) as some gateways send articles: it is decoded while
+	// reading, before Decompress. invalid base64 fails the decode
+	// with a base64.CorruptInputError. same inputs as Decompress.
+	Base64Transport bool
+	// the unbuffered input
+	src io.Reader
+	// setup() has run
+	ready bool
+	// header lines supplied by NewDecoderWithHeaders
+	headers []string
+	// header values supplied by DecodeBodyOnly, used once
+	bodyOnly     *Header
+	bodyOnlyUsed bool
+	partHeader   string
+	// parts hashed into crcHash since the last part 1
+	fullParts int
+	// crcHash and fullParts per file name so parts
+	// of different files may be interleaved
+	files map[string]*fileCRC
+	// the first fatal error, returned by every following
+	// call until Reset
+	err error
+	// names of the multipart files whose crc32= has been checked
+	verified map[string]bool
+	// result of the last Decode, DecodeSlice or DecodeAll, see Validated
+	validated bool
 	// are we waiting for an escaped char
 	awaitingSpecial bool
+	// what the active part recorded, see dropFailedPart
+	undo partUndo
+	// accept parts where =yend size= does not match
+	// the decoded length as long as the crc32 is ok
+	SizeIsEncoded bool
+	// validate the decoded length against =ybegin size= (=ypart
+	// begin= end= for multipart) instead of =yend size=, for encoders
+	// which write a wrong trailer size. Part.Size is set to it and
+	// a different trailer size is reported as WarnHeaderSize.
+	PreferHeaderSize bool
+	// accept numbers with thousands separators in the header
+	// and trailer fields, like size=1,000 or size=1 000
+	// written by some broken encoders. default strict.
+	LenientNumbers bool
+	// abort with ErrTooManyEscapes once the escape sequences of a part
+	// exceed this fraction of its decoded bytes. random data escapes
+	// about 1-2% of the bytes, far more hints at garbage or an attack.
+	// checked on every line after the first 4096 bytes and at the
+	// end of the part. 0 disables the check.
+	MaxEscapeRatio float64
+	// detect from the first chunk read whether lines end in CRLF,
+	// LF or CR and split lines on '\r' for CR alone, see LineEnding.
+	// mixed CRLF and LF lines decode with the default '\n' anyway.
+	// does nothing with NewDecoderWithLineSep or []*string input.
+	AutoLineEnding bool
+	// detected by AutoLineEnding
+	ending LineEnding
+	// read buffer kept by DecoderPool.Put for the next input
+	spareBuf *bufio.Reader
+	// line separator for the buffered input, 0 means '\n'
+	sep byte
+	// part numbers seen per filename
+	processed map[string]map[int]bool
+	// number of lines read from Buf
+	line int
+	// index of the next line to read from Dat
+	datPos int
+	// some encoders do not escape a '.' at column 0 as "=n"
+	// but double it ("..") like NNTP dot-stuffing does.
+	// if set, a line starting with ".." has the first '.' removed
+	// before decoding. '=' escapes at column 0 are always decoded.
+	ColumnZeroEscaping bool
+	// escape constants, nil means StandardProfile
+	Profile *EscapeProfile
+	// stop after the first decoded part and do not scan
+	// for another =ybegin. the reader stays positioned
+	// after the =yend line of that part, see Buffered()
+	StopAfterPart bool
+	// once run has decoded toCheck parts (or one with StopAfterPart)
+	// every further decode call returns io.EOF at once instead of
+	// scanning the rest of the input, e.g. PAR2 data or a signature
+	// after =yend, for another =ybegin. cleared by Reset.
+	SingleShot bool
+	// once toCheck parts (or one with StopAfterPart) are decoded, look
+	// for another =ybegin in the rest of the input and fail with
+	// ErrUnexpectedExtraPart if there is one, e.g. two articles
+	// concatenated by mistake. a reader is read up to the next =ybegin
+	// or EOF, lines supplied as []*string are only peeked at.
+	VerifyNoExtra bool
+	// compare the first bytes of the first part of a file with the
+	// magic bytes of its name= extension (.jpg, .png, .gif, .rar, .zip,
+	// .7z, .par2, .pdf, .gz, .mkv) and set Part.TypeMismatch if they
+	// disagree, a hint at a mislabeled or obfuscated post. other
+	// extensions and bodies streamed to ChunkFunc are not checked.
+	SniffContentType bool
+	// record the time every part takes to decode in
+	// Part.DecodeDuration. off by default: costs two time.Now per part
+	Timing bool
+	// a =ybegin without name= or with a size=, part= etc. which can
+	// not be parsed (or without size=) is skipped and the scan goes on
+	// with the next =ybegin instead of failing, e.g. a stale truncated
+	// =ybegin in front of the real one in a re-posted article.
+	// every skipped line is a WarnSkippedHeader of the part found.
+	SkipInvalidHeaders bool
+	// DecodeAll skips parts which fail and goes on with the next
+	// =ybegin. it returns the good parts together with all errors
+	// joined by errors.Join, nil if there were none.
+	ContinueOnError bool
+	// SingleShot: run is done, do not read any further
+	shotDone bool
+	// keep a copy of every encoded body line in Part.RawLines
+	// for debugging. doubles (at least) the memory used per part!
+	KeepRawLines bool
+	// give up if reading a single line takes longer.
+	// the stalled read keeps running in the background:
+	// the decoder must not be used again after ErrReadTimeout!
+	ReadTimeout time.Duration
+	// =ybegin of the active part carried begin= or end=
+	headerBeginEnd bool
+}
+
+// EscapeProfile holds the constants of a yenc dialect.
+type EscapeProfile struct {
+	// added to every byte when encoding (yenc: 42)
+	Offset byte
+	// added to critical bytes after Offset when encoding (yenc: 64)
+	EscapeOffset byte
+	// introduces an escaped byte (yenc: '=')
+	Escape byte
 }
 
+// DefaultMaxLineLength is used if Decoder.MaxLineLength is 0.
+// yenc lines are usually 128 to 256 bytes.
+const DefaultMaxLineLength = 64 * 1024
+
+// ValidateMode selects when Decode, DecodeSlice and DecodeAll
+// check the crc32= of the whole file, see Decoder.ValidateFull
+type ValidateMode int
+
+const (
+	// check once all parts of a multipart file have been decoded (default)
+	ValidateAuto ValidateMode = iota
+	// always check, fail if there is no crc32= to check against.
+	// DecodeAll checks every file, an incomplete multipart file fails
+	ValidateAlways
+	// never check the full file crc, part crcs are still checked
+	ValidateNever
+)
+
+// StandardProfile is the profile of standard yenc.
+var StandardProfile = EscapeProfile{Offset: 42, EscapeOffset: 64, Escape: '='}
+
 // you should supply only one: ior or in1 or in2!
 // toCheck should be <= 0 if unknown or any number but mostly only 1!
-// if 'in2 []string' is supplied:
-//   you have to set 'toCheck' or it will not get an EOF and will not release!
+// if 'in2 []string' is supplied the lines are consumed like from a reader
+// and decoding ends with io.EOF after the last line.
 func NewDecoder(ior io.Reader, in1 []byte, in2 []*string, toCheck int64) *Decoder {
 	var decoder Decoder
 	if ior != nil {
+		decoder.src = ior
 		decoder.Buf = bufio.NewReader(ior)
 	} else
 	if in1 != nil {
-		decoder.Buf = bufio.NewReader(bytes.NewReader(in1))
+		decoder.src = bytes.NewReader(in1)
+		decoder.Buf = bufio.NewReader(decoder.src)
 	} else
 	if in2 != nil {
 		decoder.Dat = in2
@@ -126,14 +816,349 @@ func NewDecoder(ior io.Reader, in1 []byte, in2 []*string, toCheck int64) *Decode
 	return &decoder
 } // end func yenc.NewDecoder(in1, in2)
 
+// NewDecoderWithLineSep works like NewDecoder but splits lines on sep.
+// NewDecoder splits on '\n' (LF and CRLF), a stream separated only
+// by '\r' (old mac) would be read as one giant line: use sep '\r' for those.
+// the separator does not apply to 'in2 []*string' which is already split.
+func NewDecoderWithLineSep(ior io.Reader, in1 []byte, toCheck int64, sep byte) *Decoder {
+	decoder := NewDecoder(ior, in1, nil, toCheck)
+	decoder.sep = sep
+	return decoder
+} // end func yenc.NewDecoderWithLineSep
+
+func (d *Decoder) lineSep() byte {
+	if d.sep == 0 {
+		return '\n'
+	}
+	return d.sep
+}
+
+// NewDecoderWithHeaders uses the =ybegin (and =ypart) line from header
+// for the first part and reads its body from body.
+// for transports which already split headers from the body.
+func NewDecoderWithHeaders(header []string, body io.Reader, toCheck int64) *Decoder {
+	decoder := NewDecoder(body, nil, nil, toCheck)
+	decoder.headers = header
+	return decoder
+} // end func yenc.NewDecoderWithHeaders
+
+// NewDecoderFromBufio decodes from br directly instead of wrapping
+// it in another buffer: bytes already buffered (e.g. by Peek) are
+// decoded and br can be used again after the decoder stopped.
+// BufferSize does not apply, br keeps its own size.
+func NewDecoderFromBufio(br *bufio.Reader, toCheck int64) *Decoder {
+	return &Decoder{Buf: br, toCheck: toCheck}
+} // end func yenc.NewDecoderFromBufio
+
+// NewDecoderAt reads sequentially from ra starting at offset.
+// useful to decode a single article out of a large spool file
+// without reading the file from the top. offset must not be negative.
+func NewDecoderAt(ra io.ReaderAt, offset int64, toCheck int64) (*Decoder, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("Error in yenc.NewDecoderAt: negative offset %d", offset)
+	}
+	return NewDecoder(io.NewSectionReader(ra, offset, math.MaxInt64-offset), nil, nil, toCheck), nil
+} // end func yenc.NewDecoderAt
+
+// SetReader points the decoder at a new input.
+// anything still buffered from the previous input is dropped,
+// decoded parts, the processed list and options are kept.
+// line numbers in errors start over with the new input.
+func (d *Decoder) SetReader(r io.Reader) {
+	d.setInput(r, nil)
+} // end func d.SetReader
+
+// SetBytes works like SetReader for input held in memory.
+func (d *Decoder) SetBytes(b []byte) {
+	d.setInput(bytes.NewReader(b), nil)
+} // end func d.SetBytes
+
+// SetLines works like SetReader for input which is already split into lines.
+func (d *Decoder) SetLines(lines []*string) {
+	d.setInput(nil, lines)
+} // end func d.SetLines
+
+// Reset clears everything decoded so far: parts, the processed
+// list, crc state and a latched error. input and options are kept,
+// use SetReader to decode something else.
+func (d *Decoder) Reset() {
+	d.multipart, d.total = false, 0
+	d.parts, d.part = nil, nil
+	d.Fullcrc32, d.fullcrcSet = 0, false
+	d.crcHash, d.fullParts, d.files = crc32.NewIEEE(), 0, nil
+	d.processed = nil
+	d.awaitingSpecial, d.headerBeginEnd = false, false
+	d.err, d.shotDone = nil, false
+	d.verified, d.validated = nil, false
+	d.peakBytes = 0
+} // end func d.Reset
+
+func (d *Decoder) setInput(r io.Reader, lines []*string) {
+	d.src, d.Buf, d.Dat = nil, nil, nil
+	d.dropScanner()
+	d.line, d.datPos, d.offset = 0, 0, 0
+	d.articleEnd = false
+	// checks of the old input say nothing about the new one
+	d.verified, d.validated = nil, false
+	if d.ending != EndingUnknown {
+		// detected for the old input, not set by NewDecoderWithLineSep
+		d.sep, d.ending = 0, EndingUnknown
+	}
+	// apply BufferSize, Decompress and AutoLineEnding to the new input
+	d.ready = false
+	if r != nil {
+		d.src = r
+		if buf := d.spareBuf; buf != nil && (buf.Size() == d.BufferSize || d.BufferSize <= 0 && buf.Size() == 4096) {
+			// a pooled decoder: no new buffer
+			d.spareBuf = nil
+			buf.Reset(r)
+			d.Buf = buf
+		} else if d.BufferSize > 0 {
+			d.Buf = bufio.NewReaderSize(r, d.BufferSize)
+		} else {
+			d.Buf = bufio.NewReader(r)
+		}
+	} else {
+		d.Dat = lines
+	}
+} // end func d.setInput
+
+// Buffered returns the buffered reader the decoder reads from
+// or nil if the input was supplied as []*string.
+// after Decode returned, it is positioned on the line
+// following the =yend trailer of the last decoded part.
+// this only holds if decoding stopped because 'toCheck' parts
+// had been checked: with toCheck <= 0 the decoder reads until EOF.
+// with UseScanner Buf is replaced by a reader which returns what the
+// scanner read ahead first, decoding goes on from there as well.
+func (d *Decoder) Buffered() *bufio.Reader {
+	if d.scanner != nil {
+		var rest []byte
+		if d.scanAhead != nil {
+			rest = append(rest, d.scanAhead.line...)
+		}
+		rest = append(rest, d.scanRead.Bytes()...)
+		buf := d.Buf
+		d.dropScanner()
+		d.Buf = bufio.NewReader(io.MultiReader(bytes.NewReader(rest), buf))
+	}
+	return d.Buf
+}
+
+// dropScanner forgets the scanner and what it read ahead.
+func (d *Decoder) dropScanner() {
+	d.scanner, d.scanAhead = nil, nil
+	d.scanRead.Reset()
+}
+
+// readLine reads the next line from Buf.
+// with NNTP set the line is dot-unstuffed and a lone "."
+// ends the input with io.EOF without reading any further.
+func (d *Decoder) readLine() ([]byte, error) {
+	if !d.NNTP {
+		line, err := d.readRawLine()
+		d.offset += int64(len(line))
+		return line, err
+	}
+	if d.articleEnd {
+		return nil, io.EOF
+	}
+	line, err := d.readRawLine()
+	d.offset += int64(len(line))
+	if err != nil {
+		return line, err
+	}
+	if string(bytes.TrimRight(line, "\r\n")) == "." {
+		d.articleEnd = true
+		return nil, io.EOF
+	}
+	if len(line) >= 2 && line[0] == '.' && line[1] == '.' {
+		line = line[1:]
+	}
+	return line, nil
+} // end func d.readLine
+
+// readRawLine reads the next line from Buf.
+// if ReadTimeout is set and the read does not return in time
+// ErrReadTimeout is returned.
+func (d *Decoder) readRawLine() ([]byte, error) {
+	return d.timed(d.readBytes)
+} // end func d.readRawLine
+
+// peekRawLine returns the next line from Buf without reading it,
+// see peekLine: a line which does not fit into Buf comes cut short
+// with bufio.ErrBufferFull. ReadTimeout applies like for readRawLine.
+func (d *Decoder) peekRawLine() ([]byte, error) {
+	return d.timed(func() ([]byte, error) {
+		if d.UseScanner {
+			return d.scanPeek()
+		}
+		return peekLineAfter(d.Buf, 0, d.lineSep())
+	})
+}
+
+// timed returns what read returns or ErrReadTimeout
+// if ReadTimeout is set and read does not return in time.
+func (d *Decoder) timed(read func() ([]byte, error)) ([]byte, error) {
+	if d.ReadTimeout <= 0 {
+		return read()
+	}
+	type result struct {
+		line []byte
+		err  error
+	}
+	// bufio.Reader has no deadline: read in a goroutine and wait for it
+	ch := make(chan result, 1)
+	go func() {
+		line, err := read()
+		ch <- result{line: line, err: err}
+	}()
+	timer := time.NewTimer(d.ReadTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.line, r.err
+	case <-timer.C:
+		return nil, ErrReadTimeout
+	}
+}
+
+// readBytes works like Buf.ReadBytes but returns ErrLineTooLong
+// as soon as the line exceeds MaxLineLength.
+func (d *Decoder) readBytes() ([]byte, error) {
+	if d.UseScanner {
+		return d.scanLine()
+	}
+	max := d.MaxLineLength
+	if max == 0 {
+		max = DefaultMaxLineLength
+	}
+	var line []byte
+	for {
+		frag, err := d.Buf.ReadSlice(d.lineSep())
+		line = append(line, frag...)
+		if err == bufio.ErrBufferFull {
+			if max > 0 && len(line) > max {
+				return nil, ErrLineTooLong
+			}
+			continue
+		}
+		if err == nil && max > 0 && len(bytes.TrimRight(line, "\r\n")) > max {
+			return nil, ErrLineTooLong
+		}
+		return line, err
+	}
+} // end func d.readBytes
+
+// scannedLine is a result of scanNext.
+type scannedLine struct {
+	line []byte
+	err  error
+}
+
+// scanLine reads the next line with a bufio.Scanner limited to
+// ScannerMax bytes. it returns the same as readBytes would:
+// the line with its separator or the last line and io.EOF.
+func (d *Decoder) scanLine() ([]byte, error) {
+	if ahead := d.scanAhead; ahead != nil {
+		d.scanAhead = nil
+		return ahead.line, ahead.err
+	}
+	return d.scanNext()
+}
+
+// scanPeek returns the next line like scanLine without reading it:
+// Buf is behind the scanner, it has its own lookahead.
+func (d *Decoder) scanPeek() ([]byte, error) {
+	if d.scanAhead == nil {
+		line, err := d.scanNext()
+		d.scanAhead = &scannedLine{line: line, err: err}
+	}
+	return d.scanAhead.line, d.scanAhead.err
+}
+
+// scanNext scans the next line from Buf.
+func (d *Decoder) scanNext() ([]byte, error) {
+	if d.scanner == nil {
+		max := d.ScannerMax
+		if max <= 0 {
+			max = DefaultMaxLineLength
+		}
+		sep := d.lineSep()
+		// what the scanner buffers is kept for Buffered
+		d.scanRead.Reset()
+		d.scanner = bufio.NewScanner(io.TeeReader(d.Buf, &d.scanRead))
+		d.scanner.Buffer(make([]byte, 0, min(max, 4096)), max)
+		d.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, sep); i >= 0 {
+				return i + 1, data[:i+1], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+	}
+	if !d.scanner.Scan() {
+		if err := d.scanner.Err(); err != nil {
+			if err == bufio.ErrTooLong {
+				return nil, ErrLineTooLong
+			}
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	// the token is overwritten by the next Scan
+	line := bytes.Clone(d.scanner.Bytes())
+	d.scanRead.Next(len(line))
+	if line[len(line)-1] != d.lineSep() {
+		return line, io.EOF
+	}
+	return line, nil
+} // end func d.scanNext
+
+// readString is readLine for the header lines.
+func (d *Decoder) readString() (string, error) {
+	line, err := d.readLine()
+	if err == ErrReadTimeout || err == ErrLineTooLong {
+		return "", &DecodeError{Line: d.line + 1, Err: err}
+	}
+	return string(line), err
+}
+
+// validateFull returns whether to check the full file crc
+// given the result auto of the heuristic of the caller.
+func (d *Decoder) validateFull(auto bool) bool {
+	if d.SkipCRC {
+		return false
+	}
+	switch d.ValidateFull {
+	case ValidateAlways:
+		return true
+	case ValidateNever:
+		return false
+	}
+	return auto
+}
+
 func (d *Decoder) validate() error {
 	if Debug1 {
 		log.Printf("yenc.Decoder.validate() d.part.Number=%d", d.part.Number)
 	}
-	if d.Fullcrc32 > 0 {
+	if d.Fullcrc32 > 0 || d.fullcrcSet {
+		// after the input ended d.part is the empty part
+		// of the failed search for another =ybegin
+		name := d.part.Name
+		if name == "" && len(d.parts) > 0 {
+			name = d.parts[len(d.parts)-1].Name
+		}
 		if sum := d.crcHash.Sum32(); sum != d.Fullcrc32 {
-			return fmt.Errorf("crc check failed expected %x got %x", d.Fullcrc32, sum)
+			return fmt.Errorf("%w: full file crc check failed for %q over %d parts expected %s got %s", ErrCRCMismatch, name, d.fullParts, hexCRC(d.Fullcrc32), hexCRC(sum))
 		}
+		if d.verified == nil {
+			d.verified = make(map[string]bool)
+		}
+		d.verified[name] = true
 		if Debug1 {
 			log.Printf("yenc.Decoder validated d.part.Number=%d", d.part.Number)
 		}
@@ -142,35 +1167,239 @@ func (d *Decoder) validate() error {
 	return fmt.Errorf("Error in yenc.Decoder.validate d.Fullcrc32 not set")
 }
 
+// isHeaderLine returns true if s looks like an RFC 822 header
+// line "Name: value" as found at the top of a mail or news article.
+func isHeaderLine(s string) bool {
+	i := strings.IndexByte(s, ':')
+	if i < 1 {
+		return false
+	}
+	for _, c := range []byte(s[:i]) {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// isFieldLine is a stricter isHeaderLine for a line which may as well
+// be yenc data: a field name of letters, digits and '-' and ": ".
+func isFieldLine(s string) bool {
+	name, _, ok := strings.Cut(s, ": ")
+	if !ok || name == "" {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// headerBlockLine reports whether line, read in the article header
+// block at the top of the input, belongs to it: a header field or a
+// folded line. false for the blank line closing the block and for a
+// line which shows there is no header block at all, e.g. "File: x"
+// followed by text: scanning for =ybegin goes on from that line. a
+// =ybegin line only belongs to the block if the line after it, from
+// next, is blank or a header field, else it is the real =ybegin of
+// an article without a blank line after its headers.
+func headerBlockLine(line string, next func() string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case line == "":
+		return false
+	case isHeaderLine(line), line[0] == ' ', line[0] == '\t':
+		return true
+	case strings.HasPrefix(line, "=ybegin"):
+		after := strings.TrimRight(next(), "\r\n")
+		return after == "" || isFieldLine(after)
+	}
+	return false
+}
+
+// headerContinuation returns and consumes the next line if it
+// continues a =ybegin line without name=: it must hold name=
+// and must not start with a yenc marker. returns "" otherwise.
+func (d *Decoder) headerContinuation() string {
+	isContinuation := func(line string) bool {
+		return strings.Contains(line, "name=") && !strings.HasPrefix(line, "=y")
+	}
+	if d.Buf != nil && d.headers == nil {
+		// the next line only: more input may not have arrived yet
+		peek, _ := d.peekRawLine()
+		if !isContinuation(string(peek)) {
+			return ""
+		}
+		line, err := d.readString()
+		if err != nil {
+			return ""
+		}
+		d.line++
+		return strings.TrimRight(line, "\r\n")
+	}
+	if d.Dat != nil && d.datPos < len(d.Dat) && isContinuation(*d.Dat[d.datPos]) {
+		d.datPos++
+		return *d.Dat[d.datPos-1]
+	}
+	return ""
+}
+
+// splitFields splits a header line on spaces into key=value fields.
+// with LenientNumbers thousands separators are removed from numeric
+// values: "size=1,000" and "size=1 000" both become "size=1000".
+func (d *Decoder) splitFields(line string) []string {
+	fields := strings.Split(line, " ")
+	if !d.LenientNumbers {
+		return fields
+	}
+	isNum := func(v string) bool {
+		return v != "" && strings.Trim(v, "0123456789,") == ""
+	}
+	var out []string
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if n := len(out); n > 0 && len(f) == 3 && isNum(f) {
+			// a digit group split off by a space
+			if k, v, ok := strings.Cut(out[n-1], "="); ok && isNum(v) {
+				out[n-1] = k + "=" + v + f
+				continue
+			}
+		}
+		if k, v, ok := strings.Cut(f, "="); ok && isNum(v) {
+			f = k + "=" + strings.ReplaceAll(v, ",", "")
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// isUnknownMarker returns true for a body line which starts with "=y"
+// but is none of =ybegin, =ypart and =yend, e.g. an extension like
+// =ydata. encoders never start a body line with the escape of 'y',
+// so such a line is skipped rather than decoded as data.
+func isUnknownMarker(line string) bool {
+	return strings.HasPrefix(line, "=y") && !strings.HasPrefix(line, "=ybegin") &&
+		!strings.HasPrefix(line, "=ypart") && !strings.HasPrefix(line, "=yend")
+}
+
+// utf8BOM is skipped if the input starts with it.
+const utf8BOM = "\ufeff"
+
 func (d *Decoder) readHeader() (err error) {
+	if d.bodyOnly != nil {
+		// DecodeBodyOnly: there is exactly one part
+		if d.bodyOnlyUsed {
+			return io.EOF
+		}
+		d.bodyOnlyUsed = true
+		h := d.bodyOnly
+		d.part.Name, d.part.HeaderSize, d.part.cols = h.Name, h.Size, h.Line
+		d.part.Number, d.part.Total, d.part.Begin, d.part.End = h.Part, h.Total, h.Begin, h.End
+		d.multipart, d.total = h.Part > 0, h.Total
+		if d.part.cols <= 0 {
+			d.part.cols = DefaultLine
+		}
+		return nil
+	}
 	var s string
 	// find the start of the header
-	if d.Buf != nil {
+	if d.headers != nil {
+		// supplied by NewDecoderWithHeaders: no scanning
+		for _, h := range d.headers {
+			if strings.HasPrefix(h, "=ybegin") {
+				s = h
+			} else if strings.HasPrefix(h, "=ypart") {
+				d.partHeader = h
+			}
+		}
+		d.headers = nil
+		if s == "" {
+			return fmt.Errorf("Error in yenc.Decoder.readHeader: no =ybegin in supplied headers")
+		}
+	} else if d.Buf != nil {
+		// a full article: look for =ybegin only after the header block
+		inHeaders, first := false, d.line == 0
 		for {
-			s, err = d.Buf.ReadString('\n')
-			if err != nil {
+			d.beginOffset = d.offset
+			s, err = d.readString()
+			if err != nil && (err != io.EOF || s == "") {
 				return err
 			}
+			// a last line without newline comes with io.EOF
+			d.line++
+			if first {
+				// once at the start of the input
+				if strings.HasPrefix(s, utf8BOM) {
+					s, d.beginOffset = s[len(utf8BOM):], d.beginOffset+int64(len(utf8BOM))
+				}
+				inHeaders, first = isHeaderLine(s), false
+			}
+			if inHeaders {
+				inHeaders = err == nil && headerBlockLine(s, func() string {
+					next, _ := d.peekRawLine()
+					return string(next)
+				})
+				if inHeaders {
+					continue
+				}
+			}
 			if len(s) >= 7 && s[:7] == "=ybegin" {
 				break
 			}
+			if err != nil {
+				return err
+			}
 		}
 	} else
 	if d.Dat != nil {
-		for _, sptr := range d.Dat { // s is a line
-			if len(*sptr) >= 7 && string(*sptr)[:7] == "=ybegin" {
-				s = *sptr
+		inHeaders := d.datPos == 0 && len(d.Dat) > 0 && isHeaderLine(strings.TrimPrefix(*d.Dat[0], utf8BOM))
+		for ; d.datPos < len(d.Dat); d.datPos++ { // s is a line
+			line := *d.Dat[d.datPos]
+			if d.datPos == 0 {
+				// once at the start of the input
+				line = strings.TrimPrefix(line, utf8BOM)
+			}
+			if inHeaders {
+				pos := d.datPos
+				inHeaders = headerBlockLine(line, func() string {
+					if pos+1 < len(d.Dat) {
+						return *d.Dat[pos+1]
+					}
+					return ""
+				})
+				if inHeaders {
+					continue
+				}
+			}
+			if len(line) >= 7 && line[:7] == "=ybegin" {
+				s = line
 				break
 			}
 		}
+		if d.datPos == len(d.Dat) {
+			return io.EOF
+		}
+		d.datPos++
 	}
+	// a re-wrapped =ybegin may have name= on the next line
+	if !strings.Contains(s, "name=") {
+		if next := d.headerContinuation(); next != "" {
+			s = strings.TrimRight(s, "\r\n") + " " + next
+		}
+	}
+	d.part.RawBegin = strings.TrimRight(s, "\r\n")
+	// set again by part=: a single part file may follow a multipart one
+	d.multipart, d.headerBeginEnd = false, false
+	sizeSet := false
 	// split on name= to get name first
 	parts := strings.SplitN(s[7:], "name=", 2)
 	if len(parts) > 1 {
-		d.part.Name = strings.TrimSpace(parts[1])
+		d.part.Name = unquoteName(strings.TrimSpace(parts[1]))
 	}
 	// split on sapce for other headers
-	parts = strings.Split(parts[0], " ")
+	parts = d.splitFields(parts[0])
 	for i, _ := range parts {
 		kv := strings.Split(strings.TrimSpace(parts[i]), "=")
 		if len(kv) < 2 {
@@ -178,43 +1407,100 @@ func (d *Decoder) readHeader() (err error) {
 		}
 		switch kv[0] {
 		case "size":
-			d.part.HeaderSize, _ = strconv.ParseInt(kv[1], 10, 64)
+			if d.part.HeaderSize, err = strconv.ParseInt(kv[1], 10, 64); err != nil {
+				return malformedHeader("=ybegin", kv[0], kv[1], err)
+			}
+			sizeSet = true
 		case "line":
-			d.part.cols, _ = strconv.Atoi(kv[1])
+			if d.part.cols, err = strconv.Atoi(kv[1]); err != nil {
+				return malformedHeader("=ybegin", kv[0], kv[1], err)
+			}
 		case "part":
-			d.part.Number, _ = strconv.Atoi(kv[1])
+			if d.part.Number, err = strconv.Atoi(kv[1]); err != nil {
+				return malformedHeader("=ybegin", kv[0], kv[1], err)
+			}
 			d.multipart = true
 		case "total":
-			d.total, _ = strconv.Atoi(kv[1])
+			if d.total, err = strconv.Atoi(kv[1]); err != nil {
+				return malformedHeader("=ybegin", kv[0], kv[1], err)
+			}
+			d.part.Total = d.total
+		case "begin":
+			// some encoders put begin= and end= on =ybegin and omit =ypart
+			if d.part.Begin, err = strconv.ParseInt(kv[1], 10, 64); err != nil {
+				return malformedHeader("=ybegin", kv[0], kv[1], err)
+			}
+			d.headerBeginEnd = true
+		case "end":
+			if d.part.End, err = strconv.ParseInt(kv[1], 10, 64); err != nil {
+				return malformedHeader("=ybegin", kv[0], kv[1], err)
+			}
+			d.headerBeginEnd = true
 		}
 	}
+	if !sizeSet {
+		// size= is mandatory, without it nothing can be validated
+		return fmt.Errorf("Error in yenc.Decoder.readHeader: %w name=%q", ErrMissingSize, d.part.Name)
+	}
+	if d.part.cols <= 0 {
+		// line= missing (or 0): the de-facto standard
+		d.part.cols = DefaultLine
+	}
 	return nil
 }
 
 func (d *Decoder) readPartHeader() (err error) {
 	var s string
 	// find the start of the header
-	if d.Buf != nil {
+	if d.partHeader != "" {
+		s, d.partHeader = d.partHeader, ""
+	} else if d.Buf != nil {
+		if d.headerBeginEnd {
+			// =ypart is optional if =ybegin carried begin= and end=
+			if peek, _ := d.peekRawLine(); !bytes.HasPrefix(peek, []byte("=ypart")) {
+				return nil
+			}
+		}
 		for {
-			s, err = d.Buf.ReadString('\n')
+			s, err = d.readString()
+			if err == io.EOF {
+				return fmt.Errorf("Error in yenc.Decoder.readPartHeader: %w before EOF", ErrMissingPartHeader)
+			}
 			if err != nil {
 				return err
 			}
+			d.line++
 			if len(s) >= 6 && s[:6] == "=ypart" {
 				break
 			}
+			if strings.HasPrefix(s, "=yend") || strings.HasPrefix(s, "=ybegin") {
+				return fmt.Errorf("Error in yenc.Decoder.readPartHeader: %w at line %d", ErrMissingPartHeader, d.line)
+			}
 		}
 	} else
 	if d.Dat != nil {
-		for _, sptr := range d.Dat { // s is a line
+		pos := d.datPos
+		for ; pos < len(d.Dat); pos++ { // s is a line
+			sptr := d.Dat[pos]
 			if len(*sptr) >= 6 && string(*sptr)[:6] == "=ypart" {
 				s = *sptr
 				break
 			}
+			if strings.HasPrefix(*sptr, "=yend") || strings.HasPrefix(*sptr, "=ybegin") {
+				break
+			}
+		}
+		if pos == len(d.Dat) || s == "" {
+			if d.headerBeginEnd {
+				return nil
+			}
+			return fmt.Errorf("Error in yenc.Decoder.readPartHeader: %w at line %d", ErrMissingPartHeader, pos)
 		}
+		d.datPos = pos + 1
 	}
+	d.part.partLineLen = len(strings.TrimRight(s, "\r\n"))
 	// split on space for headers
-	parts := strings.Split(s[6:], " ")
+	parts := d.splitFields(s[6:])
 	for i, _ := range parts {
 		kv := strings.Split(strings.TrimSpace(parts[i]), "=")
 		if len(kv) < 2 {
@@ -222,17 +1508,29 @@ func (d *Decoder) readPartHeader() (err error) {
 		}
 		switch kv[0] {
 		case "begin":
-			d.part.Begin, _ = strconv.ParseInt(kv[1], 10, 64)
+			if d.part.Begin, err = strconv.ParseInt(kv[1], 10, 64); err != nil {
+				return malformedHeader("=ypart", kv[0], kv[1], err)
+			}
 		case "end":
-			d.part.End, _ = strconv.ParseInt(kv[1], 10, 64)
+			if d.part.End, err = strconv.ParseInt(kv[1], 10, 64); err != nil {
+				return malformedHeader("=ypart", kv[0], kv[1], err)
+			}
 		}
 	}
 	return nil
 }
 
 func (d *Decoder) parseTrailer(line string) error {
+	pcrcSet, sizeSet, fullSet := false, false, false
+	partNum, partSet := 0, false
+	d.part.RawEnd = strings.TrimRight(line, "\r\n")
+	// some posting tools append a comment after ';'
+	// which may contain '=' as well: ignore it
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
 	// split on space for headers
-	parts := strings.Split(line, " ")
+	parts := d.splitFields(line)
 	for i, _ := range parts {
 		kv := strings.Split(strings.TrimSpace(parts[i]), "=")
 		if len(kv) < 2 {
@@ -240,41 +1538,99 @@ func (d *Decoder) parseTrailer(line string) error {
 		}
 		switch kv[0] {
 		case "size":
-			d.part.Size, _ = strconv.ParseInt(kv[1], 10, 64)
+			size, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return malformedHeader("=yend", kv[0], kv[1], err)
+			}
+			d.part.Size = size
+			sizeSet = true
 		case "pcrc32":
-			if crc64, err := strconv.ParseUint(kv[1], 16, 64); err == nil {
-				d.part.Crc32 = uint32(crc64)
+			crc64, err := strconv.ParseUint(kv[1], 16, 32)
+			if err != nil {
+				return malformedHeader("=yend", kv[0], kv[1], err)
 			}
+			d.part.Crc32 = uint32(crc64)
+			d.part.crcSet = true
+			pcrcSet = true
 		case "crc32":
-			if crc64, err := strconv.ParseUint(kv[1], 16, 64); err == nil {
-				d.Fullcrc32 = uint32(crc64)
-				d.part.Crc32 = uint32(crc64) // why it has not been set by default... i dont know
+			crc64, err := strconv.ParseUint(kv[1], 16, 32)
+			if err != nil {
+				return malformedHeader("=yend", kv[0], kv[1], err)
+			}
+			fullSet = true
+			if !d.skipPart {
+				// a part read over does not count for its file
+				d.Fullcrc32, d.fullcrcSet = uint32(crc64), true
 			}
 		case "part":
-			partNum, _ := strconv.Atoi(kv[1])
-			if partNum != d.part.Number {
-				return fmt.Errorf("yenc: =yend header out of order expected part %d got %d", d.part.Number, partNum)
+			// checked when the whole line is parsed
+			n, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return malformedHeader("=yend", kv[0], kv[1], err)
 			}
+			partNum, partSet = n, true
+		}
+	}
+	// some encoders leave out size=: fall back to =ybegin size=, which
+	// is mandatory (ErrMissingSize), or the =ypart range. only a header
+	// given to DecodeBodyOnly may have no size, then validate on the crc
+	if !sizeSet {
+		d.part.Size = d.expectedSize()
+		if d.part.Size == 0 && d.bodyOnly != nil && (pcrcSet || fullSet) {
+			d.part.Size = d.part.bodyLen()
 		}
 	}
+	// streaming posters may send =ypart begin= without end=
+	if d.multipart && d.part.Begin > 0 && d.part.End == 0 && d.part.Size > 0 {
+		d.part.End = d.part.Begin + d.part.Size - 1
+	}
+	// crc32= is the crc of the whole file: it is the crc of this part
+	// only if there is just one part
+	if fullSet && !pcrcSet && (!d.multipart || d.total == 1) && !d.skipPart {
+		d.part.Crc32 = d.Fullcrc32
+		d.part.crcSet = true
+	}
+	if partSet && partNum != d.part.Number {
+		return &ErrPartOutOfOrder{Expected: d.part.Number, Got: partNum}
+	}
 	return nil
 }
 
 func (d *Decoder) decode(line []byte) []byte {
-	i, j := 0, 0
+	pr := d.Profile
+	if pr == nil {
+		pr = &StandardProfile
+	}
+	i := 0
+	if !d.awaitingSpecial {
+		// fast path: most lines have no escapes at all,
+		// shift everything up to the first escape in a tight loop
+		n := bytes.IndexByte(line, pr.Escape)
+		if n < 0 {
+			n = len(line)
+		}
+		for k := range line[:n] {
+			line[k] -= pr.Offset
+		}
+		if n == len(line) {
+			return line
+		}
+		i = n
+	}
+	j := i
 	for ; i < len(line); i, j = i+1, j+1 {
 		// escaped chars yenc42+yenc64
 		if d.awaitingSpecial {
-			line[j] = (((line[i] - 42) & 255) - 64) & 255
+			line[j] = line[i] - pr.Offset - pr.EscapeOffset
 			d.awaitingSpecial = false
 			// if escape char - then skip and backtrack j
-		} else if line[i] == '=' {
+		} else if line[i] == pr.Escape {
 			d.awaitingSpecial = true
 			j--
 			continue
 			// normal char, yenc42
 		} else {
-			line[j] = (line[i] - 42) & 255
+			line[j] = line[i] - pr.Offset
 		}
 	}
 	// return the new (possibly shorter) slice
@@ -282,44 +1638,193 @@ func (d *Decoder) decode(line []byte) []byte {
 	return line[:len(line)-(i-j)]
 }
 
+// unstuffColumnZero removes the first of two dots at column 0.
+func unstuffColumnZero(line []byte) []byte {
+	if len(line) >= 2 && line[0] == '.' && line[1] == '.' {
+		return line[1:]
+	}
+	return line
+}
+
+// expectedSize returns the decoded size of the active part known
+// from =ybegin/=ypart or 0 if unknown.
+func (d *Decoder) expectedSize() int64 {
+	if d.multipart {
+		if d.part.Begin > 0 && d.part.End >= d.part.Begin {
+			return d.part.End - d.part.Begin + 1
+		}
+		return 0
+	}
+	return d.part.HeaderSize
+}
+
+// bodyLine decodes one trimmed body line into the active part.
+// lineNo is only used for errors.
+func (d *Decoder) bodyLine(line []byte, lineNo int, maxSize int64) error {
+	rawLen := len(line)
+	// NUL and CR are always escaped by a proper encoder,
+	// without SkipBadLines such a line is decoded as it is
+	if d.SkipBadLines && (bytes.IndexByte(line, 0x00) >= 0 || bytes.IndexByte(line, '\r') >= 0) {
+		log.Printf("Error in yenc.Decoder.bodyLine: skipping bad line %d of part %d name='%s'", lineNo, d.part.Number, d.part.Name)
+		d.part.BadLines = append(d.part.BadLines, lineNo)
+		return nil
+	}
+	if d.ColumnZeroEscaping {
+		line = unstuffColumnZero(line)
+	}
+	encLen := len(line)
+	// decode
+	b := d.decode(line)
+	// stats
+	st := &d.part.stats
+	st.Lines++
+	st.RawBytes += int64(rawLen)
+	st.Escapes += encLen - len(b)
+	if rawLen > st.MaxLine {
+		st.MaxLine = rawLen
+	}
+	// an escape as last char may exceed line= by one
+	if d.part.cols > 0 && rawLen > d.part.cols+1 {
+		d.part.longLines++
+	}
+	// a short line after a full one is fine if it is the last
+	if d.part.pendingShort {
+		d.part.fragments++
+	}
+	full := d.part.cols > 0 && rawLen >= d.part.cols-1
+	d.part.pendingShort = !full && d.part.prevFull
+	d.part.prevFull = full
+	if d.OnBytes != nil {
+		d.OnBytes(len(b))
+	}
+	// update hashs
+	if !d.SkipCRC {
+		d.part.crcHash.Write(b)
+		d.crcHash.Write(b)
+	}
+	if d.ExtraHash != nil {
+		d.ExtraHash.Write(b)
+	}
+	// decode
+	if d.ChunkFunc != nil {
+		d.part.streamed += int64(len(b))
+		if err := d.ChunkFunc(d.part, b); err != nil {
+			return &DecodeError{Line: lineNo, Err: err}
+		}
+	} else if d.ChunkSize > 0 {
+		d.part.appendChunked(b, d.ChunkSize)
+	} else {
+		d.part.Body = append(d.part.Body, b...)
+	}
+	if n := d.part.bodyLen(); maxSize > 0 && n > maxSize {
+		return &DecodeError{Line: lineNo, Err: fmt.Errorf("%w: decoded %d bytes but expected %d", ErrSizeExceeded, n, maxSize)}
+	}
+	return d.checkEscapes(lineNo, false)
+} // end func d.bodyLine
+
+// minEscapeCheck is the number of decoded bytes of a part
+// before Decoder.MaxEscapeRatio is checked on every line
+const minEscapeCheck = 4096
+
+// checkEscapes returns ErrTooManyEscapes if the escapes of the part
+// exceed MaxEscapeRatio. before the end of the part only once
+// minEscapeCheck bytes are decoded: the first lines of valid data
+// can go over the ratio on their own.
+func (d *Decoder) checkEscapes(lineNo int, end bool) error {
+	n, st := d.part.bodyLen(), &d.part.stats
+	if d.MaxEscapeRatio <= 0 || n == 0 || !end && n < minEscapeCheck {
+		return nil
+	}
+	if float64(st.Escapes) > d.MaxEscapeRatio*float64(n) {
+		return &DecodeError{Line: lineNo, Err: fmt.Errorf("%w: %d escapes in %d bytes, max ratio %g", ErrTooManyEscapes, st.Escapes, n, d.MaxEscapeRatio)}
+	}
+	return nil
+} // end func d.checkEscapes
+
 func (d *Decoder) readBody() error {
-	// ready the part body
-	d.part.Body = make([]byte, 0)
+	// ready the part body, preallocated only once
+	// the header agrees with the expected size
+	var prealloc int64
+	if d.expectSize > 0 && d.expectedSize() == d.expectSize {
+		prealloc = d.expectSize
+	}
+	d.part.Body = make([]byte, 0, prealloc)
+	if d.headerOnly || d.skipPart || d.ChunkSize > 0 || d.ChunkFunc != nil {
+		d.part.Body = nil
+	}
 	// reset special
 	d.awaitingSpecial = false
 	// setup crc hash
 	d.part.crcHash = crc32.NewIEEE()
+	d.part.crcSkipped = d.SkipCRC
+	if d.ExtraHash != nil {
+		d.ExtraHash.Reset()
+	}
+	// fail fast if the body grows beyond the size we know from the headers
+	maxSize := d.expectedSize()
 	// each line
 	if d.Buf != nil {
 		for {
-			line, err := d.Buf.ReadBytes('\n')
-			if err != nil {
+			line, err := d.readLine()
+			// a last line without newline comes with io.EOF:
+			// process it, the next read returns io.EOF alone
+			if err != nil && !(err == io.EOF && len(line) > 0) {
 				log.Printf("Error in yenc.Decoder.readBody d.Buf.ReadBytes err='%v'", err)
-				return err
+				if err == ErrReadTimeout || err == ErrLineTooLong {
+					// the line which could not be read, like readString
+					return &DecodeError{Line: d.line + 1, Err: err}
+				}
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return &DecodeError{Line: d.line, Err: err}
 			}
+			d.line++
 			// strip linefeeds (some use CRLF some LF)
-			line = bytes.TrimRight(line, "\r\n")
+			if d.TrimFunc != nil {
+				line = d.TrimFunc(line)
+			} else {
+				line = bytes.TrimRight(line, "\r\n")
+			}
 			// check for =yend
 			if len(line) >= 5 && string(line[:5]) == "=yend" {
 				if Debug1 {
 					log.Printf("yenc.Decoder d.Buf =yend d.part.Body=%d", len(d.part.Body))
 				}
-				return d.parseTrailer(string(line))
+				if d.awaitingSpecial {
+					return &DecodeError{Line: d.line - 1, Err: ErrTruncatedEscape}
+				}
+				if err := d.parseTrailer(string(line)); err != nil {
+					return &DecodeError{Line: d.line, Err: err}
+				}
+				return nil
+			}
+			if isUnknownMarker(string(line)) {
+				d.part.UnknownMarkers = append(d.part.UnknownMarkers, string(line))
+				continue
+			}
+			if d.headerOnly || d.skipPart {
+				continue
+			}
+			if d.KeepRawLines {
+				d.part.RawLines = append(d.part.RawLines, append([]byte(nil), line...))
+				d.part.rawProfile, d.part.rawColumnZero = d.Profile, d.ColumnZeroEscaping
+			}
+			if err := d.bodyLine(line, d.line, maxSize); err != nil {
+				return err
 			}
-			// decode
-			b := d.decode(line)
-			// update hashs
-			d.part.crcHash.Write(b)
-			d.crcHash.Write(b)
-			// decode
-			d.part.Body = append(d.part.Body, b...)
 		}
 	} else
 	if d.Dat != nil {
 		if Debug1 {
 			log.Printf("yenc.Decoder readBody lines d.Dat=%d", len(d.Dat))
 		}
-		for i, line := range d.Dat {
+		for ; d.datPos < len(d.Dat); d.datPos++ {
+			i := d.datPos
+			// lines split off a CRLF stream may keep their '\r'
+			// (or '\n'), which would be taken for body or trailer bytes
+			trimmed := strings.TrimRight(*d.Dat[i], "\r\n")
+			line := &trimmed
 			if len(*line) == 0 {
 				continue
 			}
@@ -331,98 +1836,442 @@ func (d *Decoder) readBody() error {
 				if Debug2 {
 					log.Printf("yenc.Decoder d.Dat =yend d.part.Body=%d", len(d.part.Body))
 				}
-				return d.parseTrailer(*line)
+				d.datPos++
+				if d.awaitingSpecial {
+					return &DecodeError{Line: i - 1, Err: ErrTruncatedEscape}
+				}
+				if err := d.parseTrailer(*line); err != nil {
+					return &DecodeError{Line: i, Err: err}
+				}
+				return nil
+			}
+			if isUnknownMarker(*line) {
+				d.part.UnknownMarkers = append(d.part.UnknownMarkers, *line)
+				continue
+			}
+			if d.headerOnly || d.skipPart {
+				continue
 			}
 			// decode
-			b := d.decode([]byte(*line))
+			b := []byte(*line)
+			if d.TrimFunc != nil {
+				b = d.TrimFunc(b)
+			}
+			if d.KeepRawLines {
+				d.part.RawLines = append(d.part.RawLines, slices.Clone(b))
+				d.part.rawProfile, d.part.rawColumnZero = d.Profile, d.ColumnZeroEscaping
+			}
 			if Debug2 {
-				log.Printf("yenc.Decoder readBody i=%d/d.Dat=%d len(line)=%d got len(b)=%d", i, len(d.Dat), len(*line), len(b))
+				log.Printf("yenc.Decoder readBody i=%d/d.Dat=%d len(line)=%d", i, len(d.Dat), len(*line))
+			}
+			if err := d.bodyLine(b, i, maxSize); err != nil {
+				return err
+			}
+		}
+	}
+	if d.bodyOnly != nil && d.Dat != nil {
+		if d.awaitingSpecial {
+			return &DecodeError{Line: len(d.Dat) - 1, Err: ErrTruncatedEscape}
+		}
+		// no =yend: size and crc come from the supplied header
+		d.part.Size = d.expectedSize()
+		if d.bodyOnly.CRCSet {
+			d.part.Crc32, d.part.crcSet = d.bodyOnly.Crc32, true
+			if d.part.Size == 0 {
+				d.part.Size = d.part.bodyLen()
 			}
-			// update hashs
-			d.part.crcHash.Write(b)
-			d.crcHash.Write(b)
-			// decode
-			d.part.Body = append(d.part.Body, b...)
 		}
+		return nil
 	}
-	return fmt.Errorf("Error unexpected EOF in yenc.Decoder.readBody")
+	line := d.line
+	if d.Dat != nil {
+		line = len(d.Dat)
+	}
+	return &DecodeError{Line: line, Err: fmt.Errorf("Error unexpected EOF in yenc.Decoder.readBody")}
 }
 
-func (d *Decoder) run() error {
-	// init hash
-	d.crcHash = crc32.NewIEEE()
-	var checked int64 = 0
-	processed := make(map[string]map[int]bool)
-	// for each part
-	for {
-		// create a part
-		d.part = new(Part)
+// dropFailedPart takes back what the part which just failed recorded,
+// so decoding can go on with the next =ybegin: a later good copy of
+// the part is not rejected as processed and the file crc does not
+// include the failed body. clears the latched error.
+func (d *Decoder) dropFailedPart() {
+	u := d.undo
+	if u.marked {
+		delete(d.processed[u.name], u.number)
+	}
+	if u.fc != nil {
+		h := u.fc.crcHash
+		*u.fc = u.saved
+		u.fc.crcHash = h
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(u.sum); err != nil {
+			// the file crc can not be trusted any more
+			delete(d.files, u.name)
+		}
+	}
+	d.undo = partUndo{}
+	d.awaitingSpecial, d.headerBeginEnd = false, false
+	d.err = nil
+} // end func d.dropFailedPart
 
-		// read the header
-		if err := d.readHeader(); err != nil {
-			if DebugThis11 {
-				// when reading from io.reader or with []bytes
-				// ^ we use a buffer which clears out while reading
-				// : but with []*string we won't hit an io.EOF while iterating over and over again!
-				// ! results in oom quickly as it generates new parts and fills them all with the same!
-				log.Printf("Debug readHeader err='%v'", err)
-			}
-			return err
+// markProcessed returns an error if part 'number' of file 'name'
+// has already been seen by this decoder.
+func (d *Decoder) markProcessed(name string, number int) error {
+	if d.verifyOnly || d.skipPart {
+		// VerifyOne keeps no state, skipped parts are not processed
+		return nil
+	}
+	if d.processed == nil {
+		d.processed = make(map[string]map[int]bool)
+	}
+	if d.processed[name] == nil {
+		// total= is untrusted input: do not size the map from it alone
+		d.processed[name] = make(map[int]bool, min(max(d.total, 0), 1024))
+	}
+	if d.processed[name][number] {
+		return fmt.Errorf("ERROR in yenc.Decoder.run() already processed fn='%s' part=%d", name, number)
+	}
+	d.processed[name][number] = true
+	return nil
+}
+
+// Merge appends the decoded parts of other to the parts of d.
+// nothing is merged if any part of other has already been
+// processed by d (same filename and part number).
+func (d *Decoder) Merge(other *Decoder) error {
+	for _, part := range other.parts {
+		if d.processed[part.Name][part.Number] {
+			return fmt.Errorf("Error in yenc.Decoder.Merge: duplicate part fn='%s' part=%d", part.Name, part.Number)
 		}
-		if Debug2 {
-			log.Printf("yenc.Decoder.run: #1 done d.readHeader() @Number=%d", d.part.Number)
+	}
+	for _, part := range other.parts {
+		if err := d.markProcessed(part.Name, part.Number); err != nil {
+			return err
 		}
-		if d.part.Name == "" {
-			return fmt.Errorf("ERROR in yenc.Decoder.run() empty Name field fn='%s' part=%d", d.part.Name, d.part.Number)
+		d.parts = append(d.parts, part)
+	}
+	return nil
+} // end func d.Merge
+
+// setup applies the options which have to be set before
+// the first read from the input.
+func (d *Decoder) setup() error {
+	if d.ready {
+		return nil
+	}
+	d.ready = true
+	if d.crcHash == nil {
+		d.crcHash = crc32.NewIEEE()
+	}
+	if d.Base64Transport && d.src != nil && d.Buf != nil && d.Buf.Buffered() == 0 {
+		d.src = base64.NewDecoder(base64.StdEncoding, d.src)
+		d.Buf.Reset(d.src)
+	}
+	if d.Decompress != nil && d.src != nil && d.Buf != nil && d.Buf.Buffered() == 0 {
+		r, err := d.Decompress(d.src)
+		if err != nil {
+			return fmt.Errorf("Error in yenc.Decoder: Decompress err='%w'", err)
 		}
-		if processed[d.part.Name] == nil {
-			processed[d.part.Name] = make(map[int]bool, d.total)
+		d.src = r
+		d.Buf.Reset(r)
+	}
+	if d.BufferSize > 0 && d.src != nil && d.Buf != nil && d.Buf.Buffered() == 0 && d.Buf.Size() != d.BufferSize {
+		d.Buf = bufio.NewReaderSize(d.src, d.BufferSize)
+	}
+	d.detectEnding()
+	return nil
+} // end func d.setup
+
+// errSkippedPart is returned by nextPart for a part not in WantParts.
+var errSkippedPart = errors.New("yenc: part skipped")
+
+// fileCRC is the full file crc state of one file.
+// partUndo holds what a part recorded before it failed
+type partUndo struct {
+	name   string
+	number int
+	// the part was added to d.processed
+	marked bool
+	// the file state before the body was read (only with
+	// ContinueOnError), nil if the body was not read
+	fc    *fileCRC
+	saved fileCRC
+	sum   []byte
+}
+
+type fileCRC struct {
+	crcHash hash.Hash32
+	parts   int
+	// crc32= of the file once a =yend carried it
+	crc    uint32
+	crcSet bool
+	// total= of the first part seen, 0 if not given
+	total int
+}
+
+func (d *Decoder) fileCRC(name string) *fileCRC {
+	if d.files == nil {
+		d.files = make(map[string]*fileCRC)
+	}
+	fc := d.files[name]
+	if fc == nil {
+		fc = &fileCRC{crcHash: crc32.NewIEEE()}
+		d.files[name] = fc
+	}
+	return fc
+}
+
+// checkTotal returns ErrInconsistentTotal if total= of the active
+// part differs from the one of the first part seen of its file.
+// part 1 starts a new file and sets total= again.
+func (d *Decoder) checkTotal() error {
+	if !d.multipart || d.part.Total == 0 {
+		return nil
+	}
+	fc := d.fileCRC(d.part.Name)
+	if fc.total == 0 || d.part.Number == 1 {
+		fc.total = d.part.Total
+		return nil
+	}
+	if fc.total != d.part.Total {
+		return fmt.Errorf("Error in yenc.Decoder: %w name=%q part=%d total=%d but %d in an earlier part", ErrInconsistentTotal, d.part.Name, d.part.Number, d.part.Total, fc.total)
+	}
+	return nil
+}
+
+// collectWarnings records the soft problems of the decoded active part.
+func (d *Decoder) collectWarnings() {
+	p := d.part
+	if p.longLines > 0 {
+		p.warn(WarnLineLength, "%d lines longer than line=%d", p.longLines, p.cols)
+	}
+	if p.fragments > 0 {
+		p.SuspectUnescapedNewline = true
+		p.warn(WarnUnescapedNewline, "%d body lines look split by an unescaped newline", p.fragments)
+	}
+	if want := d.expectedSize(); want > 0 && p.Size != want {
+		p.warn(WarnHeaderSize, "=yend size=%d but header announced %d", p.Size, want)
+	}
+}
+
+// next decodes and validates the next part and adds it to d.parts.
+// returns io.EOF if there is no further =ybegin in the input.
+// any other error is latched: the state of the decoder is undefined
+// after it and every further call returns the same error.
+func (d *Decoder) next() error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.shotDone {
+		return io.EOF
+	}
+	for {
+		err := d.nextPart()
+		if err == errSkippedPart {
+			continue
 		}
-		if processed[d.part.Name][d.part.Number] {
-			return fmt.Errorf("ERROR in yenc.Decoder.run() already processed fn='%s' part=%d", d.part.Name, d.part.Number)
+		// no input is not latched: the input may be set next
+		if err != nil && err != io.EOF && !errors.Is(err, ErrNoInput) {
+			d.err = err
+			d.trace(TraceError, "%v", err)
 		}
-		processed[d.part.Name][d.part.Number] = true // set it here or later? should not matter as we return on any err
+		return err
+	}
+} // end func d.next()
 
-		//log.Printf("yenc.Decoder.run: process #1 d.part.Number=%d", d.part.Number)
+func (d *Decoder) nextPart() error {
+	if d.Buf == nil && d.Dat == nil && d.headers == nil {
+		return fmt.Errorf("Error in yenc.Decoder: %w", ErrNoInput)
+	}
+	if err := d.setup(); err != nil {
+		return err
+	}
+	var start time.Time
+	if d.Timing {
+		start = time.Now()
+	}
+	// create a part
+	d.part = new(Part)
+	d.undo = partUndo{}
 
-		// read part header if available
-		if d.multipart {
-			if err := d.readPartHeader(); err != nil {
-				log.Printf("Debug readPartHeader err='%v'", err)
-				return err
-			}
+	// read the header
+	var err error
+	var skipped []string
+	for {
+		err = d.readHeader()
+		if !d.SkipInvalidHeaders || !(errors.Is(err, ErrMalformedHeader) || errors.Is(err, ErrMissingSize) || (err == nil && d.part.Name == "")) {
+			break
 		}
-		if Debug2 {
-			log.Printf("yenc.Decoder.run: #2 done d.readPartHeader @Number=%d", d.part.Number)
+		if err == nil {
+			err = ErrMissingName
+		}
+		d.trace(TraceError, "skipped invalid =ybegin: %v", err)
+		skipped = append(skipped, fmt.Sprintf("skipped %q: %v", d.part.RawBegin, err))
+		d.part = new(Part)
+	}
+	if err != nil {
+		if DebugThis11 {
+			// io.EOF is expected here when the input is exhausted:
+			// readers and []bytes drain the buffer, []*string moves d.datPos
+			log.Printf("Debug readHeader err='%v'", err)
+		}
+		return err
+	}
+	if Debug2 {
+		log.Printf("yenc.Decoder.run: #1 done d.readHeader() @Number=%d", d.part.Number)
+	}
+	d.trace(TraceHeader, "name=%q part=%d total=%d size=%d", d.part.Name, d.part.Number, d.part.Total, d.part.HeaderSize)
+	for _, msg := range skipped {
+		d.part.warn(WarnSkippedHeader, "%s", msg)
+	}
+	if d.part.Name == "" {
+		return fmt.Errorf("ERROR in yenc.Decoder.run() %w fn='%s' part=%d", ErrMissingName, d.part.Name, d.part.Number)
+	}
+	if err := d.checkTotal(); err != nil {
+		return err
+	}
+	// not wanted: read over it but do not record it
+	d.skipPart = d.WantParts != nil && !d.WantParts[d.part.Number]
+	if err := d.markProcessed(d.part.Name, d.part.Number); err != nil { // set it here or later? should not matter as we return on any err
+		return err
+	}
+	d.undo.name, d.undo.number, d.undo.marked = d.part.Name, d.part.Number, !d.verifyOnly && !d.skipPart
+
+	//log.Printf("yenc.Decoder.run: process #1 d.part.Number=%d", d.part.Number)
+
+	// read part header if available (DecodeBodyOnly has none)
+	if d.multipart && d.bodyOnly == nil {
+		if err := d.readPartHeader(); err != nil {
+			log.Printf("Debug readPartHeader err='%v'", err)
+			return err
 		}
-		//log.Printf("yenc.Decoder.run: process #2 d.part.Number=%d", d.part.Number)
+	}
+	if Debug2 {
+		log.Printf("yenc.Decoder.run: #2 done d.readPartHeader @Number=%d", d.part.Number)
+	}
+	if d.multipart {
+		d.trace(TracePartHeader, "begin=%d end=%d", d.part.Begin, d.part.End)
+	}
+	//log.Printf("yenc.Decoder.run: process #2 d.part.Number=%d", d.part.Number)
+	if want := d.expectedSize(); d.expectSize > 0 && want > 0 && want != d.expectSize {
+		return fmt.Errorf("Error in yenc.Decoder: %w: header announced %d bytes but %d expected", ErrSizeMismatch, want, d.expectSize)
+	}
 
-		// decode the part body
+	// the full file crc starts over with every new file,
+	// interleaved files each keep their own
+	if d.skipPart {
 		if err := d.readBody(); err != nil {
-			log.Printf("Debug readBody err='%v'", err)
 			return err
 		}
-		if Debug2 {
-			log.Printf("yenc.Decoder.run: #3 done d.readBody @Number=%d", d.part.Number)
+		return errSkippedPart
+	}
+	fc := d.fileCRC(d.part.Name)
+	if d.ContinueOnError {
+		if m, ok := fc.crcHash.(encoding.BinaryMarshaler); ok {
+			if sum, err := m.MarshalBinary(); err == nil {
+				d.undo.fc, d.undo.saved, d.undo.sum = fc, *fc, sum
+			}
 		}
-		//log.Printf("yenc.Decoder.run: process #3 d.part.Number=%d", d.part.Number)
+	}
+	if !d.multipart || d.part.Number == 1 {
+		fc.crcHash.Reset()
+		fc.parts = 0
+		fc.crc, fc.crcSet = 0, false
+	}
+	fc.parts++
+	d.crcHash, d.fullParts = fc.crcHash, fc.parts
+	d.Fullcrc32, d.fullcrcSet = fc.crc, fc.crcSet
+
+	// decode the part body
+	if err := d.readBody(); err != nil {
+		log.Printf("Debug readBody err='%v'", err)
+		return err
+	}
+	line := d.line
+	if d.Dat != nil {
+		line = d.datPos - 1
+	}
+	if err := d.checkEscapes(line, true); err != nil {
+		return err
+	}
+	fc.crc, fc.crcSet = d.Fullcrc32, d.fullcrcSet
+	if Debug2 {
+		log.Printf("yenc.Decoder.run: #3 done d.readBody @Number=%d", d.part.Number)
+	}
+	d.trace(TraceBody, "%d lines %d bytes", d.part.stats.Lines, d.part.bodyLen())
+	if d.part.streamed == 0 {
+		// bytes passed to ChunkFunc are not held
+		d.peakBytes = max(d.peakBytes, d.part.bodyLen())
+	}
+	d.trace(TraceTrailer, "size=%d crc32=%s", d.part.Size, hexCRC(d.part.Crc32))
+	//log.Printf("yenc.Decoder.run: process #3 d.part.Number=%d", d.part.Number)
+	if d.expectSize > 0 && d.part.Size != d.expectSize {
+		return fmt.Errorf("Error in yenc.Decoder: %w: =yend size=%d but %d expected", ErrSizeMismatch, d.part.Size, d.expectSize)
+	}
+
+	if d.ExtraHash != nil {
+		d.part.ExtraSum = d.ExtraHash.Sum(nil)
+	}
+	d.collectWarnings()
+	if want := d.expectedSize(); d.PreferHeaderSize && want > 0 {
+		// the WarnHeaderSize warning keeps the =yend size=
+		d.part.Size = want
+	}
 
-		// validate part
-		if err := d.part.validate(); err != nil {
-			log.Printf("Error yenc.Decoder.run: validate @Number=%d err='%v' d.part='%#v'", d.part.Number, err, d.part)
+	// validate part (nothing to validate if the body was skipped)
+	if err := d.part.validate(); err != nil && !d.headerOnly {
+		switch {
+		case d.SizeIsEncoded && errors.Is(err, ErrSizeEncoded):
+		case d.allowMissingCRC && errors.Is(err, ErrMissingCRC):
+			d.part.warn(WarnMissingCRC, "no pcrc32= or crc32= in =yend")
+		case d.SkipBadLines && len(d.part.BadLines) > 0:
+			d.part.warn(WarnBadLines, "%d bad lines skipped: %v", len(d.part.BadLines), err)
+		default:
+			log.Printf("Error yenc.Decoder.run: validate @Number=%d err='%v' d.part='%s'", d.part.Number, err, d.part)
+			if d.part.SuspectUnescapedNewline {
+				return fmt.Errorf("%w (suspect unescaped newline in body)", err)
+			}
 			return err
 		}
-		//log.Printf("yenc.Decoder.run: process #4 d.part.Number=%d", d.part.Number)
+	}
+	//log.Printf("yenc.Decoder.run: process #4 d.part.Number=%d", d.part.Number)
+
+	d.trace(TraceValidated, "%d bytes", d.part.bodyLen())
+	if d.SniffContentType {
+		d.part.TypeMismatch = d.part.typeMismatch()
+	}
+	if d.Timing {
+		d.part.DecodeDuration = time.Since(start)
+	}
 
-		// add part to list
+	// add part to list
+	if !d.verifyOnly {
 		d.parts = append(d.parts, d.part)
+	}
 
-		if Debug3 {
-			log.Printf("yenc.Decoder.run: #4 done d.validate @Number=%d parts=%d", d.part.Number, len(d.parts))
+	if Debug3 {
+		log.Printf("yenc.Decoder.run: #4 done d.validate @Number=%d parts=%d", d.part.Number, len(d.parts))
+	}
+	return nil
+} // end func d.nextPart()
+
+func (d *Decoder) run() error {
+	// init hash
+	d.crcHash = crc32.NewIEEE()
+	var checked int64 = 0
+	// for each part
+	for {
+		if err := d.next(); err != nil {
+			return err
 		}
 
 		checked++
-		if d.toCheck > 0 && checked == d.toCheck {
+		if (d.toCheck > 0 && checked == d.toCheck) || d.StopAfterPart {
+			d.shotDone = d.SingleShot
+			if d.VerifyNoExtra {
+				if err := d.checkNoExtra(checked); err != nil {
+					return err
+				}
+			}
 			break
 		}
 		//log.Printf("processed d.part.Number=%d", d.part.Number)
@@ -430,20 +2279,470 @@ func (d *Decoder) run() error {
 	return nil
 } // end func d.run()
 
+// checkNoExtra returns ErrUnexpectedExtraPart if there is another
+// =ybegin after the checked parts, see VerifyNoExtra.
+func (d *Decoder) checkNoExtra(checked int64) error {
+	found := false
+	if d.Dat != nil {
+		for _, line := range d.Dat[d.datPos:] {
+			if strings.HasPrefix(*line, "=ybegin") {
+				found = true
+				break
+			}
+		}
+	} else if d.Buf != nil {
+		for {
+			line, err := d.readLine()
+			if bytes.HasPrefix(line, []byte("=ybegin")) {
+				found = true
+				break
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("Error in yenc.Decoder.checkNoExtra: err='%w'", err)
+			}
+		}
+	}
+	if found {
+		return fmt.Errorf("Error in yenc.Decoder.checkNoExtra: %w after %d parts", ErrUnexpectedExtraPart, checked)
+	}
+	return nil
+} // end func d.checkNoExtra
+
+// All returns an iterator over the parts as they get decoded.
+// iteration ends at the end of the input, after yielding an error
+// or when the caller breaks out of the loop.
+// toCheck and StopAfterPart do not apply.
+func (d *Decoder) All() iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for {
+			if err := d.next(); err != nil {
+				if err != io.EOF {
+					yield(nil, err)
+				}
+				return
+			}
+			if !yield(d.part, nil) {
+				return
+			}
+		}
+	}
+} // end func d.All
+
+// DecodeChan decodes the parts in r in a goroutine of its own and
+// sends every validated part on the parts channel in input order.
+// the crc32= of a multipart file is checked once its last part is
+// seen. both channels are closed when r is exhausted, the first error
+// is sent on the error channel before. the caller must drain parts
+// until it is closed, then receive from the error channel (nil if
+// decoding went fine).
+func DecodeChan(r io.Reader) (<-chan *Part, <-chan error) {
+	parts, errc := make(chan *Part), make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(parts)
+		d := NewDecoder(r, nil, nil, -1)
+		for part, err := range d.All() {
+			if err != nil {
+				errc <- fmt.Errorf("Error in yenc.DecodeChan err='%w'", err)
+				return
+			}
+			if d.lastPartSeen() && d.fullcrcSet && d.validateFull(true) {
+				if err := d.validate(); err != nil {
+					errc <- fmt.Errorf("Error in yenc.DecodeChan err='%w'", err)
+					return
+				}
+			}
+			parts <- part
+		}
+	}()
+	return parts, errc
+} // end func DecodeChan
+
+// FinalCRC returns the crc32= of the whole file the last decoded
+// part belongs to and whether a =yend of that file carried it.
+// it is cleared for every new file, a later file never reports
+// the crc32= of an earlier one.
+func (d *Decoder) FinalCRC() (uint32, bool) {
+	return d.Fullcrc32, d.fullcrcSet
+}
+
+// PeakBytes returns the most decoded bytes one part held in Body or
+// Chunks since the decoder was created or Reset, e.g. to choose between
+// decoding in memory and ChunkFunc spilling to disk. the read buffer
+// and bytes streamed to ChunkFunc are not counted.
+func (d *Decoder) PeakBytes() int64 {
+	return d.peakBytes
+} // end func d.PeakBytes
+
+// CurrentPart returns the part number of the part being decoded,
+// 0 before the first =ybegin and for single part files.
+// it is only meaningful during an active decode, e.g. inside a
+// loop over All, and must be called from the decoding goroutine.
+func (d *Decoder) CurrentPart() int {
+	if d.part == nil {
+		return 0
+	}
+	return d.part.Number
+} // end func d.CurrentPart
+
+// lastPartSeen reports whether the active part is the last part
+// of a multipart file and all its parts have been hashed in order.
+func (d *Decoder) lastPartSeen() bool {
+	if !d.multipart || d.part == nil {
+		return false
+	}
+	last := (d.total > 0 && d.part.Number == d.total) || (d.part.End > 0 && d.part.End == d.part.HeaderSize)
+	return last && d.fullParts == d.part.Number
+}
+
+// DecodeAll decodes all parts of the input and returns them.
+// every part is validated against its pcrc32=
+// once the last part of a multipart file has been decoded
+// the crc32= from its =yend is checked against all parts
+// (see ValidateFull). the first error stops decoding
+// unless ContinueOnError is set.
+func (d *Decoder) DecodeAll() ([]*Part, error) {
+	if d.shotDone {
+		// SingleShot: the run is over
+		return nil, io.EOF
+	}
+	d.validated = false
+	var errs []error
+	// files whose crc32= has been checked
+	checked := make(map[string]bool)
+	for {
+		offset, datPos := d.offset, d.datPos
+		if err := d.next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			err = fmt.Errorf("Error in yenc.DecodeAll #1 err='%w'", err)
+			if !d.ContinueOnError {
+				return nil, err
+			}
+			// go on with the next =ybegin unless the input did not move
+			if d.offset == offset && d.datPos == datPos {
+				return nil, errors.Join(append(errs, err)...)
+			}
+			errs = append(errs, err)
+			d.dropFailedPart()
+			continue
+		}
+		// ValidateAlways checks every file like Decode does: a single
+		// part at once, a multipart file when its last part is seen
+		last := d.lastPartSeen() || !d.multipart && d.ValidateFull == ValidateAlways
+		if last && d.validateFull(d.fullcrcSet) {
+			checked[d.part.Name] = true
+			if err := d.validate(); err != nil {
+				err = fmt.Errorf("Error in yenc.DecodeAll #2 d.validate err='%w'", err)
+				if !d.ContinueOnError {
+					return nil, err
+				}
+				errs = append(errs, err)
+			}
+		}
+	}
+	if d.validateFull(false) {
+		// ValidateAlways: a multipart file whose last part never
+		// came fails like it does in Decode
+		for _, file := range groupFiles(d.parts) {
+			if !file.Multipart || checked[file.Name] {
+				continue
+			}
+			err := fmt.Errorf("Error in yenc.DecodeAll #3: full file crc of %q not checked: parts missing or no crc32=", file.Name)
+			if !d.ContinueOnError {
+				return nil, err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(d.parts) == 0 {
+		err := fmt.Errorf("Error in yenc.DecodeAll: %w", ErrNotYEnc)
+		if len(errs) > 0 {
+			err = errors.Join(append(errs, err)...)
+		}
+		return nil, err
+	}
+	d.validated = len(errs) == 0 && d.allVerified(d.parts)
+	return slices.Clone(d.parts), errors.Join(errs...)
+} // end func DecodeAll
+
+// allVerified reports whether every file the parts belong to has been
+// checked against a crc of the whole file: the crc32= of a multipart
+// file or the crc of a single part, which covers the whole file.
+func (d *Decoder) allVerified(parts []*Part) bool {
+	for _, file := range groupFiles(parts) {
+		if file.Multipart {
+			if !d.verified[file.Name] {
+				return false
+			}
+			continue
+		}
+		for _, p := range file.Parts {
+			if !p.crcSet || p.crcSkipped || len(p.BadLines) > 0 {
+				return false
+			}
+		}
+	}
+	return len(parts) > 0
+}
+
+// Validated reports whether the data returned by the last Decode,
+// DecodeSlice or DecodeAll has been checked against the crc of the
+// whole file and matched. it is false if that check was skipped, e.g.
+// for an incomplete multipart file, with ValidateNever or SkipCRC, or
+// for a part without crc accepted by DecodeResult.
+// the size and crc of every single part are always checked.
+func (d *Decoder) Validated() bool {
+	return d.validated
+} // end func d.Validated
+
+// PartsByName returns the decoded parts grouped by file name,
+// each in the order they were decoded. use it after DecodeAll
+// on a stream with the parts of several files interleaved.
+func (d *Decoder) PartsByName() map[string][]*Part {
+	files := make(map[string][]*Part)
+	for _, p := range d.parts {
+		files[p.Name] = append(files[p.Name], p)
+	}
+	return files
+} // end func d.PartsByName
+
+// DecodeN decodes up to n parts and returns them.
+// it returns less than n parts if the input ends before.
+// the reader stays positioned after the =yend line
+// of the last returned part, see Buffered()
+func (d *Decoder) DecodeN(n int) ([]*Part, error) {
+	if d.shotDone {
+		// SingleShot: the run is over
+		return nil, io.EOF
+	}
+	start := len(d.parts)
+	for i := 0; i < n; i++ {
+		if err := d.next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("Error in yenc.DecodeN err='%w'", err)
+		}
+	}
+	if len(d.parts) == start {
+		return nil, fmt.Errorf("Error in yenc.DecodeN: %w", ErrNotYEnc)
+	}
+	return slices.Clone(d.parts[start:]), nil
+} // end func DecodeN
+
+// DecodeArticle decodes a single part from an NNTP article body
+// as read from the server: dot-stuffed, CRLF line endings and
+// terminated by a lone ".". lines after the "." are not decoded.
+func DecodeArticle(r io.Reader) (*Part, error) {
+	d := NewDecoder(r, nil, nil, 1)
+	d.NNTP = true
+	return d.Decode()
+} // end func DecodeArticle
+
+// DecodeConn works like DecodeArticle reading from conn, which must
+// be positioned at the start of an article body. the read deadline
+// of conn is set to deadline and cleared again before returning.
+// if the deadline passes the error wraps ErrReadTimeout.
+// the decoder reads ahead: do not pipeline commands on conn.
+func DecodeConn(conn net.Conn, deadline time.Time) (*Part, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("Error in yenc.DecodeConn: %w", err)
+	}
+	defer conn.SetReadDeadline(time.Time{})
+	part, err := DecodeArticle(conn)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, fmt.Errorf("Error in yenc.DecodeConn: %w: %w", ErrReadTimeout, err)
+	}
+	return part, err
+} // end func DecodeConn
+
+// DecodeWithRaw decodes the first part in r like DecodeSinglePart and
+// also returns the raw article it was decoded from: the bytes from its
+// =ybegin line through the =yend line including the line terminator,
+// e.g. to store it for verifying it again later. r is not read far
+// beyond the =yend line, the bytes after it are dropped.
+func DecodeWithRaw(r io.Reader) (*Part, []byte, error) {
+	var raw bytes.Buffer
+	d := NewDecoder(io.TeeReader(r, &raw), nil, nil, 1)
+	d.ValidateFull = ValidateNever
+	d.StopAfterPart = true
+	part, err := d.Decode()
+	if err != nil {
+		return nil, nil, err
+	}
+	// the tee holds what the buffer read ahead too
+	return part, raw.Bytes()[d.beginOffset:d.offset], nil
+} // end func DecodeWithRaw
+
+// DecodeRange decodes the first part in r but keeps only the decoded
+// bytes in [start, start+length) of its body in Body, e.g. to serve a
+// range request without holding the whole body. the whole body is
+// still decoded, so size and crc are checked as usual. a range
+// reaching past the end of the body is cut short.
+func DecodeRange(r io.Reader, start, length int64) (*Part, error) {
+	if start < 0 || length < 0 {
+		return nil, fmt.Errorf("Error in yenc.DecodeRange: invalid range start=%d length=%d", start, length)
+	}
+	d := NewDecoder(r, nil, nil, 1)
+	body, pos, end := make([]byte, 0, min(length, 1<<20)), int64(0), start+length
+	if length > math.MaxInt64-start {
+		// start+length overflows: up to the end of the body
+		end = math.MaxInt64
+	}
+	d.ChunkFunc = func(p *Part, b []byte) error {
+		from, to := max(start-pos, 0), min(end-pos, int64(len(b)))
+		if from < to {
+			body = append(body, b[from:to]...)
+		}
+		pos += int64(len(b))
+		return nil
+	}
+	part, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	// nothing was streamed to the caller: the bytes are in Body
+	part.Body, part.streamed = body, 0
+	return part, nil
+} // end func DecodeRange
+
+// DecodeSinglePart decodes exactly one part from r, the first one found,
+// whatever its part= and total= say. the part is checked against its
+// pcrc32= (or crc32= if single part) but the full file crc is never
+// checked: use it for workers downloading single segments.
+func DecodeSinglePart(r io.Reader) (*Part, error) {
+	d := NewDecoder(r, nil, nil, 1)
+	d.ValidateFull = ValidateNever
+	return d.Decode()
+} // end func DecodeSinglePart
+
+// DecodeHeaderOnly reads the =ybegin, =ypart and =yend lines of
+// the first part in r and skips the body lines without decoding.
+// the returned part has a nil Body and no crc is checked.
+// use it to index name, part, total and size of many articles.
+func DecodeHeaderOnly(r io.Reader) (*Part, error) {
+	d := NewDecoder(r, nil, nil, 1)
+	d.headerOnly = true
+	return d.Decode()
+} // end func DecodeHeaderOnly
+
+// DecodeExpectingSize decodes the first part in r when its decoded
+// size is known in advance, e.g. from an index: the body is allocated
+// once with size bytes and a header or trailer announcing another
+// size fails with ErrSizeMismatch before the body is read.
+// the body is allocated only once the header announced size.
+func DecodeExpectingSize(r io.Reader, size int64) (*Part, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("Error in yenc.DecodeExpectingSize: invalid size %d", size)
+	}
+	d := NewDecoder(r, nil, nil, 1)
+	d.expectSize = size
+	return d.Decode()
+} // end func DecodeExpectingSize
+
+// DecodeSegment decodes the first part in r, the article of an nzb
+// segment, and checks that it belongs where the nzb says: a name= other
+// than expectedName returns *ErrNameMismatch, a part number other than
+// expectedPart *ErrPartMismatch. a single part article is part 1 like
+// its only nzb segment. the decoded part is returned with a mismatch.
+func DecodeSegment(r io.Reader, expectedName string, expectedPart int) (*Part, error) {
+	part, err := NewDecoder(r, nil, nil, 1).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("Error in yenc.DecodeSegment err='%w'", err)
+	}
+	if part.Name != expectedName {
+		return part, &ErrNameMismatch{Expected: expectedName, Got: part.Name}
+	}
+	if number := max(part.Number, 1); number != expectedPart {
+		return part, &ErrPartMismatch{Expected: expectedPart, Got: number}
+	}
+	return part, nil
+} // end func DecodeSegment
+
+// Header holds the =ybegin, =ypart and =yend values of a part
+// parsed by the transport, see DecodeBodyOnly.
+type Header struct {
+	Name string
+	// =ybegin line= and size= (of the whole file for multipart)
+	Line int
+	Size int64
+	// multipart only: part= total= and the =ypart range
+	Part, Total int
+	Begin, End  int64
+	// pcrc32= (crc32= for a single part) if CRCSet
+	Crc32  uint32
+	CRCSet bool
+}
+
+// DecodeBodyOnly decodes body lines without =ybegin and =ypart whose
+// header values have been parsed already: hdr is used instead of
+// scanning for a header. a =yend line at the end of lines is still
+// parsed, without it the size and crc to validate come from hdr.
+func DecodeBodyOnly(lines []*string, hdr Header) (*Part, error) {
+	d := NewDecoder(nil, nil, lines, 1)
+	d.bodyOnly = &hdr
+	part, err := d.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("Error in yenc.DecodeBodyOnly err='%w'", err)
+	}
+	return part, nil
+} // end func DecodeBodyOnly
+
+// VerifyOne decodes and validates the first part in r and
+// returns nil if its size and crc match the trailer.
+// the part is neither returned nor recorded, which saves the
+// allocations of Decode when checking many articles.
+func VerifyOne(r io.Reader) error {
+	d := NewDecoder(r, nil, nil, 1)
+	d.verifyOnly = true
+	if err := d.next(); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("Error in yenc.VerifyOne: %w", ErrNotYEnc)
+		}
+		return fmt.Errorf("Error in yenc.VerifyOne err='%w'", err)
+	}
+	return nil
+} // end func VerifyOne
+
+// DecodeResult works like Decode but returns the part together
+// with its decode stats and non-fatal warnings.
+// a part without crc in its trailer is returned with a
+// WarnMissingCRC warning instead of failing.
+func (d *Decoder) DecodeResult() (*Result, error) {
+	// only for this call: Decode on the same decoder stays strict
+	defer func(allow bool) { d.allowMissingCRC = allow }(d.allowMissingCRC)
+	d.allowMissingCRC = true
+	part, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Part: part, Stats: part.stats, Warnings: slices.Clone(part.warnings)}, nil
+} // end func DecodeResult
+
 // return a single part from yenc data
 func (d *Decoder) DecodeSlice() (part *Part, err error) {
+	if d.shotDone {
+		// SingleShot: the run is over
+		return nil, io.EOF
+	}
 	//d := &Decoder{dat: input}
+	d.validated = false
 	if err = d.run(); err != nil && err != io.EOF {
 		log.Printf("Error in yenc.DecodeSlice #1 err='%v'", err)
 		return nil, err
 	}
 	if len(d.parts) == 0 {
 		log.Printf("Error in yenc.DecodeSlice #2 'len(d.parts) == 0' err='%v'", err)
-		return nil, fmt.Errorf("no yenc parts found")
+		return nil, fmt.Errorf("Error in yenc.DecodeSlice: %w", ErrNotYEnc)
 	}
 	// validate multipart only if all parts are present
 	//if !d.multipart || len(d.parts) == d.parts[len(d.parts)-1].Number { //  ?????????
-	if d.multipart && len(d.parts) > 1 && len(d.parts) == d.parts[len(d.parts)-1].Number {
+	if d.validateFull(d.multipart && len(d.parts) > 1 && len(d.parts) == d.parts[len(d.parts)-1].Number) {
 		if Debug3 {
 			log.Printf("yenc.DecodeSlice d.validate() d.multipart=%t parts=%d", d.multipart, len(d.parts))
 		}
@@ -455,29 +2754,92 @@ func (d *Decoder) DecodeSlice() (part *Part, err error) {
 	if Debug3 {
 		log.Printf("OK yenc.DecodeSlice return yPart.Number=%d Body=%d parts=%d", d.parts[0].Number, len(d.parts[0].Body), len(d.parts))
 	}
+	d.validated = d.allVerified(d.parts[:1])
 	return d.parts[0], nil
 } // end func DecodeSlice
 
+// DecodeSliceAt decodes the first part in b and returns it with
+// the number of bytes consumed up to and including its =yend line,
+// so the next article in a concatenated blob starts at b[n:].
+func DecodeSliceAt(b []byte) (*Part, int, error) {
+	r := bytes.NewReader(b)
+	d := NewDecoder(r, nil, nil, 1)
+	part, err := d.DecodeSlice()
+	if err != nil {
+		return nil, 0, err
+	}
+	return part, len(b) - r.Len() - d.Buf.Buffered(), nil
+} // end func DecodeSliceAt
+
 func (d *Decoder) Decode() (part *Part, err error) {
+	if d.shotDone {
+		// SingleShot: the run is over
+		return nil, io.EOF
+	}
 	//d := &Decoder{buf: bufio.NewReader(input)}
+	d.validated = false
 	if err = d.run(); err != nil && err != io.EOF {
-		return nil, fmt.Errorf("Error in yenc.Decode #1 err='%v'", err)
+		return nil, fmt.Errorf("Error in yenc.Decode #1 err='%w'", err)
 	}
 	if len(d.parts) == 0 {
-		return nil, fmt.Errorf("Error in yenc.Decode #2 'len(d.parts) == 0' err='%#v'", err)
+		return nil, fmt.Errorf("Error in yenc.Decode #2 'len(d.parts) == 0' %w", ErrNotYEnc)
 	}
 	// validate multipart only if all parts are present
 	//if !d.multipart || len(d.parts) == d.parts[len(d.parts)-1].Number { //  ?????????
-	if d.multipart && len(d.parts) > 1 && len(d.parts) == d.parts[len(d.parts)-1].Number {
+	if d.validateFull(d.multipart && len(d.parts) > 1 && len(d.parts) == d.parts[len(d.parts)-1].Number) {
 		if Debug3 {
 			log.Printf("yenc.Decode d.validate() d.multipart=%t parts=%d", d.multipart, len(d.parts))
 		}
 		if err := d.validate(); err != nil {
-			return nil, fmt.Errorf("Error in yenc.Decode #3 d.validate err='%v'", err)
+			return nil, fmt.Errorf("Error in yenc.Decode #3 d.validate err='%w'", err)
 		}
 	}
 	if Debug3 {
 		log.Printf("OK yenc.Decode return yPart.Number=%d Body=%d parts=%d", d.parts[0].Number, len(d.parts[0].Body), len(d.parts))
 	}
+	d.validated = d.allVerified(d.parts[:1])
 	return d.parts[0], nil
 } // end func Decode
+
+// DecodeToBuffered decodes a single part like Decode but writes the
+// decoded bytes to bw instead of Body, see ChunkFunc. bw is flushed
+// every flushEvery bytes (<= 0 only when bufio fills up) and once at
+// the end, so writes to a slow sink are batched as the caller likes.
+// a ChunkFunc set on the decoder is still called before every write.
+func (d *Decoder) DecodeToBuffered(bw *bufio.Writer, flushEvery int) (*Part, error) {
+	if bw == nil {
+		return nil, fmt.Errorf("Error in yenc.DecodeToBuffered: nil writer")
+	}
+	chunkFunc := d.ChunkFunc
+	defer func() { d.ChunkFunc = chunkFunc }()
+	pending := 0
+	d.ChunkFunc = func(p *Part, b []byte) error {
+		if chunkFunc != nil {
+			if err := chunkFunc(p, b); err != nil {
+				return err
+			}
+		}
+		for len(b) > 0 {
+			n := len(b)
+			if flushEvery > 0 {
+				n = min(n, flushEvery-pending)
+			}
+			if _, err := bw.Write(b[:n]); err != nil {
+				return err
+			}
+			b, pending = b[n:], pending+n
+			if flushEvery > 0 && pending == flushEvery {
+				pending = 0
+				if err := bw.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	part, err := d.Decode()
+	if ferr := bw.Flush(); ferr != nil && err == nil {
+		return nil, fmt.Errorf("Error in yenc.DecodeToBuffered: flush err='%w'", ferr)
+	}
+	return part, err
+} // end func DecodeToBuffered
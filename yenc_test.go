@@ -1,10 +1,52 @@
 package yenc
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/rand"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// loadFixture reads the named test fixture or stops the test.
+func loadFixture(tb testing.TB, name string) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		tb.Fatalf("could not open %s for testing", name)
+	}
+	return data
+}
+
+// splitLines splits s on CRLF into the lines input of NewDecoder.
+func splitLines(s string) []*string {
+	var lines []*string
+	for _, line := range strings.Split(s, "\r\n") {
+		lines = append(lines, &line)
+	}
+	return lines
+}
+
+// fixtureLines is splitLines of data without the final line break.
+func fixtureLines(data []byte) []*string {
+	return splitLines(strings.TrimSpace(string(data)))
+}
+
 func TestSinglepartDecode(t *testing.T) {
 	f, err := os.Open("singlepart_test.yenc")
 	if err != nil {
@@ -33,3 +75,3140 @@ func TestMultipartDecode(t *testing.T) {
 	// out,_ := os.Create("joystick.jpg")
 	// out.Write(part.Body)
 }
+
+func TestPartCRCHex(t *testing.T) {
+	f, err := os.Open("singlepart_test.yenc")
+	if err != nil {
+		t.Fatal("could not open singlepart_test.yenc for testing")
+	}
+	decoder := NewDecoder(f, nil, nil, -1)
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.CRCHex() != "ded29f4f" {
+		t.Errorf("expected computed crc %s got %s", "ded29f4f", part.CRCHex())
+	}
+	if part.ExpectedCRCHex() != part.CRCHex() {
+		t.Errorf("expected trailer crc %s got %s", part.CRCHex(), part.ExpectedCRCHex())
+	}
+	if (&Part{Crc32: 0xbeef}).ExpectedCRCHex() != "0000beef" {
+		t.Errorf("expected zero padded crc got %s", (&Part{Crc32: 0xbeef}).ExpectedCRCHex())
+	}
+}
+
+func TestDecoderAtOffset(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	spool := bytes.NewReader(append(append([]byte{}, single...), multi...))
+	decoder, err := NewDecoderAt(spool, int64(len(single)), 1)
+	if err != nil {
+		t.Fatalf("expected a decoder: %v", err)
+	}
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Name != "joystick.jpg" {
+		t.Errorf("expected part name %s got %s", "joystick.jpg", part.Name)
+	}
+	if _, err := NewDecoderAt(spool, -1, 1); err == nil {
+		t.Errorf("expected a negative offset to fail")
+	}
+}
+
+func TestSizeIsEncoded(t *testing.T) {
+	f, err := os.Open("sizeencoded_test.yenc")
+	if err != nil {
+		t.Fatal("could not open sizeencoded_test.yenc for testing")
+	}
+	defer f.Close()
+	decoder := NewDecoder(f, nil, nil, -1)
+	_, err = decoder.Decode()
+	if !errors.Is(err, ErrSizeEncoded) {
+		t.Fatalf("expected ErrSizeEncoded got %v", err)
+	}
+	f.Seek(0, io.SeekStart)
+	decoder = NewDecoder(f, nil, nil, -1)
+	decoder.SizeIsEncoded = true
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(part.Body) != 584 {
+		t.Errorf("expected body size %d got %d", 584, len(part.Body))
+	}
+}
+
+func TestBufferedRemaining(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	decoder := NewDecoder(bytes.NewReader(append(append([]byte{}, single...), multi...)), nil, nil, 1)
+	if _, err := decoder.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	rest, err := io.ReadAll(decoder.Buffered())
+	if err != nil {
+		t.Fatalf("expected to read remaining: %v", err)
+	}
+	if !bytes.Equal(rest, multi) {
+		t.Errorf("expected remaining %d bytes got %d", len(multi), len(rest))
+	}
+}
+
+func TestMalformedSize(t *testing.T) {
+	f, err := os.Open("badsize_test.yenc")
+	if err != nil {
+		t.Fatal("could not open badsize_test.yenc for testing")
+	}
+	defer f.Close()
+	decoder := NewDecoder(f, nil, nil, -1)
+	_, err = decoder.Decode()
+	if !errors.Is(err, ErrMalformedHeader) {
+		t.Fatalf("expected ErrMalformedHeader got %v", err)
+	}
+	if !strings.Contains(err.Error(), `size="58four"`) {
+		t.Errorf("expected offending field in error got %v", err)
+	}
+}
+
+func TestCROnlyDecode(t *testing.T) {
+	f, err := os.Open("cronly_test.yenc")
+	if err != nil {
+		t.Fatal("could not open cronly_test.yenc for testing")
+	}
+	defer f.Close()
+	decoder := NewDecoderWithLineSep(f, nil, -1, '\r')
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.CRCHex() != "ded29f4f" {
+		t.Errorf("expected computed crc %s got %s", "ded29f4f", part.CRCHex())
+	}
+}
+
+func TestMergeDecoders(t *testing.T) {
+	part1 := loadFixture(t, "multipart_test.yenc")
+	// same body posted as the next segment of joystick.jpg
+	part2 := bytes.ReplaceAll(part1, []byte("part=1"), []byte("part=2"))
+	d1 := NewDecoder(nil, part1, nil, 1)
+	if _, err := d1.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	d2 := NewDecoder(nil, part2, nil, 1)
+	if _, err := d2.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if err := d1.Merge(d2); err != nil {
+		t.Fatalf("expected to merge: %v", err)
+	}
+	if len(d1.parts) != 2 || d1.parts[1].Number != 2 {
+		t.Errorf("expected %d parts got %d", 2, len(d1.parts))
+	}
+	// merging the same segment again must fail
+	d3 := NewDecoder(nil, part2, nil, 1)
+	if _, err := d3.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if err := d1.Merge(d3); err == nil {
+		t.Errorf("expected duplicate part error")
+	}
+	if len(d1.parts) != 2 {
+		t.Errorf("expected %d parts after failed merge got %d", 2, len(d1.parts))
+	}
+}
+
+func TestDecodeErrorLine(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	// cut the article after the 3rd body line
+	lines := bytes.SplitAfter(single, []byte("\n"))
+	truncated := bytes.Join(lines[:4], nil)
+	decoder := NewDecoder(nil, truncated, nil, -1)
+	_, err := decoder.Decode()
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("expected DecodeError got %v", err)
+	}
+	if decErr.Line != 4 {
+		t.Errorf("expected error at line %d got %d", 4, decErr.Line)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+	// bad trailer on line 7
+	decoder = NewDecoder(nil, bytes.Replace(single, []byte("crc32=ded29f4f"), []byte("crc32=xyz"), 1), nil, -1)
+	_, err = decoder.Decode()
+	if !errors.As(err, &decErr) || decErr.Line != 7 {
+		t.Errorf("expected DecodeError at line %d got %v", 7, err)
+	}
+}
+
+func TestColumnZeroEscaping(t *testing.T) {
+	f, err := os.Open("colzero_test.yenc")
+	if err != nil {
+		t.Fatal("could not open colzero_test.yenc for testing")
+	}
+	defer f.Close()
+	decoder := NewDecoder(f, nil, nil, -1)
+	if _, err = decoder.Decode(); err == nil {
+		t.Fatalf("expected dot-stuffed lines to fail without ColumnZeroEscaping")
+	}
+	f.Seek(0, io.SeekStart)
+	decoder = NewDecoder(f, nil, nil, -1)
+	decoder.ColumnZeroEscaping = true
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(part.Body) != 400 || part.Body[0] != 0x04 {
+		t.Errorf("expected 400 bytes starting with 0x04 got %d", len(part.Body))
+	}
+}
+
+func TestIsYEnc(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	if !IsYEnc(single) {
+		t.Errorf("expected singlepart_test.yenc to be yenc")
+	}
+	if !IsYEnc(append([]byte("Subject: test\r\n\r\n"), single...)) {
+		t.Errorf("expected yenc after article headers")
+	}
+	if IsYEnc([]byte("begin 644 file.bin\nM86)C\n")) {
+		t.Errorf("expected uuencode not to be yenc")
+	}
+	if IsYEnc(append(bytes.Repeat([]byte("x"), SniffLen), single...)) {
+		t.Errorf("expected =ybegin beyond SniffLen not to be found")
+	}
+	if _, err := NewDecoder(nil, []byte("begin 644 file.bin\nM86)C\n"), nil, 1).Decode(); !errors.Is(err, ErrNotYEnc) {
+		t.Errorf("expected ErrNotYEnc for uuencode got %v", err)
+	}
+	if _, err := NewDecoder(nil, []byte("no yenc here\n"), nil, -1).DecodeAll(); !errors.Is(err, ErrNotYEnc) {
+		t.Errorf("expected ErrNotYEnc from DecodeAll got %v", err)
+	}
+}
+
+func TestIsLooksLikeUU(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	for _, tc := range []struct {
+		data string
+		uu   bool
+	}{
+		{"begin 644 file.bin\nM86)C\n`\nend\n", true},
+		{"Subject: test\r\n\r\nbegin 0755 run.sh\r\n", true},
+		{"begin-base64 644 file.bin\nYWJj\n", false},
+		{"begin 999 file.bin\n", false},
+		{"begin 644\n", false},
+		{"let us begin 644 times\n", false},
+		{string(single), false},
+	} {
+		if got := IsLooksLikeUU([]byte(tc.data)); got != tc.uu {
+			t.Errorf("%.30q: expected uuencode %t got %t", tc.data, tc.uu, got)
+		}
+	}
+}
+
+func TestHeaderKind(t *testing.T) {
+	for _, tc := range []struct {
+		line          string
+		multipart, ok bool
+	}{
+		{"=ybegin line=128 size=584 name=testfile.txt\r\n", false, true},
+		{"=ybegin part=1 total=2 line=128 size=19338 name=joystick.jpg", true, true},
+		{"=ybegin part=2 line=128 size=19338 name=joystick.jpg", true, true},
+		{"=ybegin line=128 size=584 name=part=1.bin", false, true},
+		{"=ybegin line=128 name=nosize.bin", false, false},
+		{"=ypart begin=1 end=11250", false, false},
+		{"Subject: test", false, false},
+	} {
+		multipart, ok := HeaderKind(tc.line)
+		if multipart != tc.multipart || ok != tc.ok {
+			t.Errorf("%q: expected multipart=%t ok=%t got %t %t", tc.line, tc.multipart, tc.ok, multipart, ok)
+		}
+	}
+	for file, want := range map[string]bool{"singlepart_test.yenc": false, "multipart_test.yenc": true} {
+		data := loadFixture(t, file)
+		line, _, _ := bytes.Cut(data, []byte("\n"))
+		if multipart, ok := HeaderKind(string(line)); !ok || multipart != want {
+			t.Errorf("%s: expected multipart=%t got %t ok=%t", file, want, multipart, ok)
+		}
+	}
+}
+
+func TestVerifyNoExtra(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	extra := append(append(bytes.Clone(single), "trailing junk\r\n"...), single...)
+	for name, decoder := range map[string]*Decoder{
+		"reader": NewDecoder(bytes.NewReader(extra), nil, nil, 1),
+		"lines":  NewDecoder(nil, nil, splitLines(string(extra)), 1),
+	} {
+		decoder.VerifyNoExtra = true
+		if _, err := decoder.Decode(); !errors.Is(err, ErrUnexpectedExtraPart) {
+			t.Errorf("%s: expected ErrUnexpectedExtraPart got %v", name, err)
+		}
+	}
+	for name, decoder := range map[string]*Decoder{
+		"reader": NewDecoder(bytes.NewReader(append(bytes.Clone(single), "trailing junk\r\n"...)), nil, nil, 1),
+		"lines":  NewDecoder(nil, nil, splitLines(string(single)), 1),
+	} {
+		decoder.VerifyNoExtra = true
+		if _, err := decoder.Decode(); err != nil {
+			t.Errorf("%s: expected to decode: %v", name, err.Error())
+		}
+	}
+}
+
+func TestPartOutOfOrder(t *testing.T) {
+	multi := loadFixture(t, "multipart_test.yenc")
+	multi = bytes.Replace(multi, []byte("=yend size=11250 part=1"), []byte("=yend size=11250 part=3"), 1)
+	decoder := NewDecoder(nil, multi, nil, -1)
+	_, err := decoder.Decode()
+	var orderErr *ErrPartOutOfOrder
+	if !errors.As(err, &orderErr) {
+		t.Fatalf("expected ErrPartOutOfOrder got %v", err)
+	}
+	if orderErr.Expected != 1 || orderErr.Got != 3 {
+		t.Errorf("expected part 1 got 3 but got %d and %d", orderErr.Expected, orderErr.Got)
+	}
+}
+
+func TestStopAfterPart(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	// trailing junk which looks like a broken header
+	junk := []byte("-- \r\nsignature\r\n=ybegin this is not a header\r\n")
+	decoder := NewDecoder(nil, append(append([]byte{}, single...), junk...), nil, -1)
+	if _, err := decoder.Decode(); err == nil {
+		t.Fatalf("expected trailing junk to fail without StopAfterPart")
+	}
+	decoder = NewDecoder(nil, append(append([]byte{}, single...), junk...), nil, -1)
+	decoder.StopAfterPart = true
+	if _, err := decoder.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	rest, _ := io.ReadAll(decoder.Buffered())
+	if !bytes.Equal(rest, junk) {
+		t.Errorf("expected the trailing junk to stay unread got %q", rest)
+	}
+}
+
+func TestBeginEndInHeader(t *testing.T) {
+	f, err := os.Open("nopartline_test.yenc")
+	if err != nil {
+		t.Fatal("could not open nopartline_test.yenc for testing")
+	}
+	defer f.Close()
+	decoder := NewDecoder(f, nil, nil, -1)
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Begin != 1 || part.End != 11250 {
+		t.Errorf("expected begin=1 end=11250 got begin=%d end=%d", part.Begin, part.End)
+	}
+	if len(part.Body) != 11250 {
+		t.Errorf("expected body size %d got %d", 11250, len(part.Body))
+	}
+}
+
+func TestKeepRawLines(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	decoder := NewDecoder(nil, single, nil, -1)
+	decoder.KeepRawLines = true
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	lines := bytes.Split(single, []byte("\r\n"))
+	if len(part.RawLines) != 5 {
+		t.Fatalf("expected %d raw lines got %d", 5, len(part.RawLines))
+	}
+	for i, raw := range part.RawLines {
+		if !bytes.Equal(raw, lines[i+1]) {
+			t.Errorf("expected raw line %d to match the encoded line", i)
+		}
+	}
+	decoder = NewDecoder(nil, single, nil, -1)
+	if part, _ = decoder.Decode(); part.RawLines != nil {
+		t.Errorf("expected no raw lines by default")
+	}
+}
+
+func TestSizeExceeded(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	single = bytes.Replace(single, []byte("size=584 name"), []byte("size=200 name"), 1)
+	decoder := NewDecoder(nil, single, nil, -1)
+	_, err := decoder.Decode()
+	if !errors.Is(err, ErrSizeExceeded) {
+		t.Fatalf("expected ErrSizeExceeded got %v", err)
+	}
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) || decErr.Line != 3 {
+		t.Errorf("expected to fail on line %d got %v", 3, err)
+	}
+}
+
+func TestCRC32Helpers(t *testing.T) {
+	f, err := os.Open("multipart_test.yenc")
+	if err != nil {
+		t.Fatal("could not open multipart_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := NewDecoder(f, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if CRC32(part.Body) != part.ComputedCRC32() || CRC32Hex(part.Body) != "bfae5c0b" {
+		t.Errorf("expected pcrc32 %s got %s", "bfae5c0b", CRC32Hex(part.Body))
+	}
+	if CRC32Hex(nil) != "00000000" {
+		t.Errorf("expected crc of empty data to be zero got %s", CRC32Hex(nil))
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	lines := bytes.SplitAfter(single, []byte("\n"))
+	for _, tc := range []struct {
+		name string
+		sent []byte
+		line int
+	}{
+		// stalls in readHeader on the line after the junk
+		{"header", []byte("junk\r\n"), 2},
+		// the header and two body lines, stalls on the third body line
+		{"body", bytes.Join(lines[:3], nil), 4},
+	} {
+		pr, pw := io.Pipe()
+		go pw.Write(tc.sent)
+		decoder := NewDecoder(pr, nil, nil, -1)
+		decoder.ReadTimeout = 50 * time.Millisecond
+		_, err := decoder.Decode()
+		pw.Close()
+		if !errors.Is(err, ErrReadTimeout) {
+			t.Fatalf("%s: expected ErrReadTimeout got %v", tc.name, err)
+		}
+		var decErr *DecodeError
+		if !errors.As(err, &decErr) || decErr.Line != tc.line {
+			t.Errorf("%s: expected timeout on line %d got %v", tc.name, tc.line, err)
+		}
+	}
+}
+
+// latin1 maps every byte to the rune with the same value
+type latin1 struct{}
+
+func (latin1) Bytes(b []byte) ([]byte, error) {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return []byte(string(runes)), nil
+}
+
+func TestDecodedName(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	single = bytes.Replace(single, []byte("name=testfile.txt"), []byte("name=caf\xe9.txt"), 1)
+	part, err := NewDecoder(nil, single, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Name != "caf\xe9.txt" {
+		t.Errorf("expected raw name to be kept got %q", part.Name)
+	}
+	name, err := part.DecodedName(latin1{})
+	if err != nil || name != "café.txt" {
+		t.Errorf("expected decoded name %q got %q err=%v", "café.txt", name, err)
+	}
+}
+
+func TestBodyString(t *testing.T) {
+	text := "caf\xe9 greetings\r\n"
+	var buf bytes.Buffer
+	if err := Encode(&buf, []byte(text), &EncodeOptions{Name: "readme.nfo"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	part, err := NewDecoder(nil, buf.Bytes(), nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if got := part.BodyString(); got != text {
+		t.Errorf("expected %q got %q", text, got)
+	}
+	got, err := part.BodyStringCharset(latin1{})
+	if err != nil || got != "café greetings\r\n" {
+		t.Errorf("expected converted text got %q err=%v", got, err)
+	}
+	decoder := NewDecoder(nil, buf.Bytes(), nil, -1)
+	decoder.ChunkSize = 4
+	part, err = decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if got := part.BodyString(); got != text {
+		t.Errorf("expected %q from chunks got %q", text, got)
+	}
+}
+
+func TestAllIterator(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	input := append(append([]byte{}, single...), multi...)
+	var names []string
+	for part, err := range NewDecoder(nil, input, nil, -1).All() {
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err)
+		}
+		names = append(names, part.Name)
+	}
+	if len(names) != 2 || names[0] != "testfile.txt" || names[1] != "joystick.jpg" {
+		t.Errorf("expected testfile.txt and joystick.jpg got %v", names)
+	}
+	// []*string input ends too
+	lines := splitLines(string(input))
+	count := 0
+	for _, err := range NewDecoder(nil, nil, lines, -1).All() {
+		if err != nil {
+			t.Fatalf("expected to decode lines: %v", err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected %d parts from lines got %d", 2, count)
+	}
+	// stop early
+	decoder := NewDecoder(nil, input, nil, -1)
+	for range decoder.All() {
+		break
+	}
+	if len(decoder.parts) != 1 {
+		t.Errorf("expected decoding to stop after %d part got %d", 1, len(decoder.parts))
+	}
+}
+
+func TestEmptyPart(t *testing.T) {
+	f, err := os.Open("empty_test.yenc")
+	if err != nil {
+		t.Fatal("could not open empty_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := NewDecoder(f, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(part.Body) != 0 || part.Name != "empty.bin" {
+		t.Errorf("expected empty body got %d bytes", len(part.Body))
+	}
+}
+
+func TestDecodeN(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	empty := loadFixture(t, "empty_test.yenc")
+	decoder := NewDecoder(bytes.NewReader(bytes.Join([][]byte{single, multi, empty}, nil)), nil, nil, -1)
+	parts, err := decoder.DecodeN(2)
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err)
+	}
+	if len(parts) != 2 || parts[1].Name != "joystick.jpg" {
+		t.Fatalf("expected 2 parts got %d", len(parts))
+	}
+	rest, _ := io.ReadAll(decoder.Buffered())
+	if !bytes.Equal(rest, empty) {
+		t.Errorf("expected reader to be positioned after the 2nd part")
+	}
+	decoder = NewDecoder(nil, single, nil, -1)
+	if parts, err = decoder.DecodeN(5); err != nil || len(parts) != 1 {
+		t.Errorf("expected 1 part from a single article got %d err=%v", len(parts), err)
+	}
+}
+
+func TestFinalCRCPerFile(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	// a multipart file of one part without total= and without crc32=
+	one := bytes.Replace(single, []byte("=ybegin line=128 size=584 name=testfile.txt \r\n"),
+		[]byte("=ybegin part=1 line=128 size=584 name=one.txt\r\n=ypart begin=1 end=584\r\n"), 1)
+	one = bytes.Replace(one, []byte("crc32=ded29f4f"), []byte("part=1 pcrc32=ded29f4f"), 1)
+	stream := append(slices.Clone(multi), one...)
+	decoder := NewDecoder(nil, stream, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected the crc32= of the first file not to apply to the second: %v", err)
+	}
+	if _, ok := decoder.FinalCRC(); ok {
+		t.Errorf("expected no final crc for the second file")
+	}
+	path := filepath.Join(t.TempDir(), "stream.yenc")
+	if err := os.WriteFile(path, stream, 0644); err != nil {
+		t.Fatalf("could not write %s: %v", path, err)
+	}
+	if err := VerifyDir(t.Context(), []string{path}, 1)[path]; err != nil {
+		t.Errorf("expected VerifyDir to pass: %v", err)
+	}
+	parts, errc := DecodeChan(bytes.NewReader(stream))
+	for range parts {
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("expected DecodeChan to pass: %v", err)
+	}
+	// a single part without any crc must not get the crc32= of the file before
+	nocrc := bytes.Replace(single, []byte(" crc32=ded29f4f"), nil, 1)
+	_, err := NewDecoder(nil, append(slices.Clone(multi), nocrc...), nil, -1).DecodeAll()
+	if !errors.Is(err, ErrMissingCRC) || errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected ErrMissingCRC got %v", err)
+	}
+}
+
+func TestDecodeAllFinalCRC(t *testing.T) {
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	decoder := NewDecoder(nil, multi, nil, -1)
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected %d parts got %d", 3, len(parts))
+	}
+	crc, ok := decoder.FinalCRC()
+	if !ok || crc != 0x4c251c57 {
+		t.Errorf("expected final crc %08x got %08x ok=%t", 0x4c251c57, crc, ok)
+	}
+	// the last part has pcrc32 and crc32: the part keeps its pcrc32
+	if parts[2].Crc32 != 0x0bde115d {
+		t.Errorf("expected pcrc32 %08x on the last part got %08x", 0x0bde115d, parts[2].Crc32)
+	}
+	decoder = NewDecoder(nil, bytes.Replace(multi, []byte("crc32=4c251c57"), []byte("crc32=4c251c58"), 1), nil, -1)
+	if _, err = decoder.DecodeAll(); err == nil || !strings.Contains(err.Error(), "full file crc check failed") {
+		t.Errorf("expected full crc check to fail got %v", err)
+	}
+	// a broken pcrc32 fails the part, before the whole file is checked
+	decoder = NewDecoder(nil, bytes.Replace(multi, []byte("pcrc32=0bde115d"), []byte("pcrc32=0bde115e"), 1), nil, -1)
+	if _, err = decoder.DecodeAll(); err == nil || !strings.Contains(err.Error(), "crc check failed for part 3") {
+		t.Errorf("expected part crc check to fail got %v", err)
+	}
+}
+
+func TestDecoderWithHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		file    string
+		headers int
+		name    string
+	}{
+		{"singlepart_test.yenc", 1, "testfile.txt"},
+		{"multipart_test.yenc", 2, "joystick.jpg"},
+	} {
+		data := loadFixture(t, tc.file)
+		lines := bytes.SplitAfterN(data, []byte("\n"), tc.headers+1)
+		var header []string
+		for _, line := range lines[:tc.headers] {
+			header = append(header, string(line))
+		}
+		want, err := NewDecoder(nil, data, nil, -1).Decode()
+		if err != nil {
+			t.Fatalf("expected to decode %s: %v", tc.file, err)
+		}
+		part, err := NewDecoderWithHeaders(header, bytes.NewReader(lines[tc.headers]), -1).Decode()
+		if err != nil {
+			t.Fatalf("expected to decode %s with headers: %v", tc.file, err)
+		}
+		if part.Name != tc.name || !bytes.Equal(part.Body, want.Body) || part.Begin != want.Begin || part.End != want.End {
+			t.Errorf("expected %s to match the fixture decoded in one piece", tc.file)
+		}
+	}
+}
+
+func TestDecodeArticle(t *testing.T) {
+	article := loadFixture(t, "article_test.yenc")
+	// the next server response must not be taken as body
+	next := []byte("223 0 <next@article> status\r\n")
+	part, err := DecodeArticle(bytes.NewReader(append(append([]byte{}, article...), next...)))
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(part.Body) != 400 || part.Body[0] != 0x04 {
+		t.Errorf("expected 400 unstuffed bytes starting with 0x04 got %d", len(part.Body))
+	}
+	// the article without the terminator must not decode as complete
+	if _, err = DecodeArticle(bytes.NewReader(bytes.Replace(article, []byte("=yend"), []byte(".\r\n=yend"), 1))); err == nil {
+		t.Errorf("expected a lone dot before =yend to end the article")
+	}
+}
+
+// countingReader counts the calls to Read and the bytes read
+type countingReader struct {
+	r     io.Reader
+	reads int
+	n     int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestBufferSize(t *testing.T) {
+	data := bytes.Repeat([]byte("buffer size test payload "), 40000)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "buffer.txt"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	small := &countingReader{r: bytes.NewReader(buf.Bytes())}
+	if _, err := NewDecoder(small, nil, nil, -1).Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err)
+	}
+	large := &countingReader{r: bytes.NewReader(buf.Bytes())}
+	decoder := NewDecoder(large, nil, nil, -1)
+	decoder.BufferSize = 64 * 1024
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err)
+	}
+	if !bytes.Equal(part.Body, data) {
+		t.Errorf("expected decoded body to match input")
+	}
+	if decoder.Buffered().Size() != 64*1024 || large.reads >= small.reads {
+		t.Errorf("expected fewer reads with a 64k buffer got %d vs %d", large.reads, small.reads)
+	}
+}
+
+func BenchmarkBufferSize(b *testing.B) {
+	data := bytes.Repeat([]byte("buffer size benchmark payload "), 64*1024)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "bench.txt"}); err != nil {
+		b.Fatalf("expected to encode: %v", err)
+	}
+	for _, size := range []int{0, 64 * 1024} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			reads := 0
+			for i := 0; i < b.N; i++ {
+				r := &countingReader{r: bytes.NewReader(buf.Bytes())}
+				decoder := NewDecoder(r, nil, nil, -1)
+				decoder.BufferSize = size
+				if _, err := decoder.Decode(); err != nil {
+					b.Fatalf("expected to decode: %v", err)
+				}
+				reads += r.reads
+			}
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+		})
+	}
+}
+
+func TestTrimFunc(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	// a source padding every line with a space before CRLF
+	padded := bytes.ReplaceAll(single, []byte("\r\n"), []byte(" \r\n"))
+	if _, err := NewDecoder(nil, padded, nil, -1).Decode(); err == nil {
+		t.Fatalf("expected padded lines to fail with the default trim")
+	}
+	decoder := NewDecoder(nil, padded, nil, -1)
+	decoder.TrimFunc = func(line []byte) []byte {
+		return bytes.TrimSuffix(bytes.TrimRight(line, "\r\n"), []byte(" "))
+	}
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.CRCHex() != "ded29f4f" {
+		t.Errorf("expected computed crc %s got %s", "ded29f4f", part.CRCHex())
+	}
+}
+
+func TestDecodeResult(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	res, err := NewDecoder(nil, single, nil, -1).DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err)
+	}
+	if res.Part.Name != "testfile.txt" || res.Stats.Lines != 5 || len(res.Warnings) != 0 {
+		t.Errorf("expected 5 lines and no warnings got %+v %v", res.Stats, res.Warnings)
+	}
+	if res.Stats.RawBytes != 596 || res.Stats.Escapes != 12 || res.Stats.MaxLine != 128 {
+		t.Errorf("unexpected stats %+v", res.Stats)
+	}
+	// no crc, line= too short and a header size mismatch
+	broken := bytes.Replace(single, []byte(" crc32=ded29f4f"), nil, 1)
+	broken = bytes.Replace(broken, []byte("line=128 size=584"), []byte("line=64 size=585"), 1)
+	if _, err = NewDecoder(nil, broken, nil, -1).Decode(); !errors.Is(err, ErrMissingCRC) {
+		t.Fatalf("expected Decode to fail with ErrMissingCRC got %v", err)
+	}
+	res, err = NewDecoder(nil, broken, nil, -1).DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to decode with warnings: %v", err)
+	}
+	kinds := map[WarningKind]bool{}
+	for _, w := range res.Warnings {
+		kinds[w.Kind] = true
+	}
+	if !kinds[WarnMissingCRC] || !kinds[WarnLineLength] || !kinds[WarnHeaderSize] {
+		t.Errorf("expected missing crc, line length and header size warnings got %v", res.Warnings)
+	}
+	// a later Decode on the same decoder does not accept a missing crc
+	decoder := NewDecoder(nil, broken, nil, -1)
+	if _, err := decoder.DecodeResult(); err != nil {
+		t.Fatalf("expected to decode with warnings: %v", err)
+	}
+	decoder.SetBytes(bytes.Replace(broken, []byte("testfile.txt"), []byte("other.txt"), 1))
+	if _, err := decoder.Decode(); !errors.Is(err, ErrMissingCRC) {
+		t.Errorf("expected Decode after DecodeResult to fail with ErrMissingCRC got %v", err)
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	// a body without line breaks
+	garbage := append(bytes.SplitAfter(single, []byte("\n"))[0], bytes.Repeat([]byte("x"), 1<<20)...)
+	_, err := NewDecoder(nil, garbage, nil, -1).Decode()
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("expected ErrLineTooLong got %v", err)
+	}
+	decoder := NewDecoder(nil, single, nil, -1)
+	decoder.MaxLineLength = 100
+	if _, err = decoder.Decode(); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("expected ErrLineTooLong for 128 byte lines got %v", err)
+	}
+	decoder = NewDecoder(nil, single, nil, -1)
+	decoder.MaxLineLength = 128
+	if _, err = decoder.Decode(); err != nil {
+		t.Errorf("expected 128 byte lines to pass got %v", err)
+	}
+}
+
+func TestFirstBodyLine(t *testing.T) {
+	// body starts on the line directly after =ybegin
+	data := []byte("=ybegin line=128 size=3 name=abc.bin\r\nklm\r\n=yend size=3 crc32=" + CRC32Hex([]byte("ABC")) + "\r\n")
+	lines := fixtureLines(data)
+	for _, decoder := range []*Decoder{
+		NewDecoder(bytes.NewReader(data), nil, nil, -1),
+		NewDecoder(nil, data, nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if string(part.Body) != "ABC" {
+			t.Errorf("expected ABC got %q", part.Body)
+		}
+	}
+}
+
+func TestDecodeHeaderOnly(t *testing.T) {
+	f, err := os.Open("multipart_test.yenc")
+	if err != nil {
+		t.Fatal("could not open multipart_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := DecodeHeaderOnly(f)
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Body != nil {
+		t.Errorf("expected nil body got %d bytes", len(part.Body))
+	}
+	if part.Name != "joystick.jpg" || part.Number != 1 || part.Total != 0 || part.Begin != 1 || part.End != 11250 {
+		t.Errorf("unexpected headers %+v", part)
+	}
+	if part.Crc32 != 0xbfae5c0b {
+		t.Errorf("expected pcrc32 bfae5c0b got %08x", part.Crc32)
+	}
+}
+
+func BenchmarkDecodeHeaderOnly(b *testing.B) {
+	data := loadFixture(b, "multipart_full_test.yenc")
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeHeaderOnly(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSkipCRC(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	// wrong crcs are not noticed without hashing
+	broken := bytes.Replace(data, []byte("pcrc32=2c883d44"), []byte("pcrc32=2c883d45"), 1)
+	broken = bytes.Replace(broken, []byte("crc32=4c251c57"), []byte("crc32=4c251c58"), 1)
+	decoder := NewDecoder(nil, broken, nil, -1)
+	decoder.SkipCRC = true
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(parts) != 3 || parts[0].ComputedCRC32() != 0 {
+		t.Errorf("expected 3 parts without crc computed got %d", len(parts))
+	}
+	// but sizes still are
+	broken = bytes.Replace(data, []byte("size=3332"), []byte("size=3331"), 1)
+	decoder = NewDecoder(nil, broken, nil, -1)
+	decoder.SkipCRC = true
+	if _, err := decoder.DecodeAll(); err == nil {
+		t.Error("expected a wrong size to fail")
+	}
+	if _, err := NewDecoder(nil, data, nil, -1).DecodeAll(); err != nil {
+		t.Fatalf("expected to decode with crc: %v", err.Error())
+	}
+}
+
+func BenchmarkSkipCRC(b *testing.B) {
+	data := make([]byte, 8<<20)
+	rand.New(rand.NewSource(199)).Read(data)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "large.bin"}); err != nil {
+		b.Fatal(err)
+	}
+	for _, skip := range []bool{false, true} {
+		b.Run(fmt.Sprintf("SkipCRC=%t", skip), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				decoder := NewDecoder(bytes.NewReader(buf.Bytes()), nil, nil, -1)
+				decoder.SkipCRC = skip
+				if _, err := decoder.Decode(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPartWithoutEnd(t *testing.T) {
+	f, err := os.Open("openend_test.yenc")
+	if err != nil {
+		t.Fatal("could not open openend_test.yenc for testing")
+	}
+	defer f.Close()
+	parts, err := NewDecoder(f, nil, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts got %d", len(parts))
+	}
+	for i, want := range [][2]int64{{1, 3334}, {3335, 6668}, {6669, 10000}} {
+		if parts[i].Begin != want[0] || parts[i].End != want[1] {
+			t.Errorf("part %d expected begin=%d end=%d got begin=%d end=%d", i+1, want[0], want[1], parts[i].Begin, parts[i].End)
+		}
+	}
+	if err := CheckContiguous(parts); err != nil {
+		t.Errorf("expected contiguous parts got %v", err)
+	}
+}
+
+func TestTrailerWithoutSize(t *testing.T) {
+	f, err := os.Open("nosize_test.yenc")
+	if err != nil {
+		t.Fatal("could not open nosize_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := NewDecoder(f, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Size != 584 || len(part.Body) != 584 {
+		t.Errorf("expected size 584 from the header got size=%d body=%d", part.Size, len(part.Body))
+	}
+	data := loadFixture(t, "nosize_test.yenc")
+	// =ybegin size=0 is a size: the crc alone does not pass the body
+	zero := bytes.Replace(data, []byte("size=584"), []byte("size=0"), 1)
+	if _, err := NewDecoder(nil, zero, nil, -1).Decode(); err == nil {
+		t.Errorf("expected a body after =ybegin size=0 to fail")
+	}
+	// a header without size from the transport validates on the crc
+	lines := splitLines(string(data))[1:]
+	part, err = DecodeBodyOnly(lines, Header{Name: "testfile.txt"})
+	if err != nil {
+		t.Fatalf("expected to decode body only on the crc: %v", err)
+	}
+	if part.Size != 584 {
+		t.Errorf("expected size 584 from the body got %d", part.Size)
+	}
+}
+
+func TestSetInput(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	decoder := NewDecoder(nil, single, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	decoder.SetReader(bytes.NewReader(multi))
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode after SetReader: %v", err.Error())
+	}
+	if len(parts) != 2 || parts[1].Name != "joystick.jpg" {
+		t.Errorf("expected the parts of both inputs got %d", len(parts))
+	}
+	var lines []*string
+	for _, line := range strings.Split(strings.TrimSpace(string(single)), "\n") {
+		line = strings.TrimRight(line, "\r")
+		lines = append(lines, &line)
+	}
+	decoder.SetLines(lines)
+	// the single part has already been processed by this decoder
+	if _, err := decoder.DecodeAll(); err == nil {
+		t.Errorf("expected already processed error after SetLines")
+	}
+}
+
+func TestCombineCRC32(t *testing.T) {
+	f, err := os.Open("multipart_full_test.yenc")
+	if err != nil {
+		t.Fatal("could not open multipart_full_test.yenc for testing")
+	}
+	defer f.Close()
+	parts, err := NewDecoder(f, nil, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	var full []byte
+	for _, part := range parts {
+		full = append(full, part.Body...)
+	}
+	// hand the parts over out of order, the trailer
+	// size does not matter, only the decoded data
+	parts[0], parts[2] = parts[2], parts[0]
+	parts[1].Size = 1
+	if got, want := CombineCRC32(parts), CRC32(full); got != want || got != 0x4c251c57 {
+		t.Errorf("expected combined crc %08x got %08x", want, got)
+	}
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	for split := 0; split <= len(data); split++ {
+		a := &Part{Begin: 1, Body: data[:split], Crc32: CRC32(data[:split])}
+		b := &Part{Begin: int64(split) + 1, Body: data[split:], Crc32: CRC32(data[split:])}
+		if got := CombineCRC32([]*Part{a, b}); got != CRC32(data) {
+			t.Errorf("split at %d: expected %08x got %08x", split, CRC32(data), got)
+		}
+	}
+}
+
+func TestMissingHeaderSize(t *testing.T) {
+	f, err := os.Open("nobeginsize_test.yenc")
+	if err != nil {
+		t.Fatal("could not open nobeginsize_test.yenc for testing")
+	}
+	defer f.Close()
+	if _, err = NewDecoder(f, nil, nil, -1).Decode(); !errors.Is(err, ErrMissingSize) {
+		t.Errorf("expected ErrMissingSize got %v", err)
+	}
+}
+
+func TestTrailerComment(t *testing.T) {
+	f, err := os.Open("comment_test.yenc")
+	if err != nil {
+		t.Fatal("could not open comment_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := NewDecoder(f, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Crc32 != 0xded29f4f {
+		t.Errorf("expected crc32 ded29f4f from before the comment got %08x", part.Crc32)
+	}
+}
+
+func TestVerifyOne(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	if err := VerifyOne(bytes.NewReader(single)); err != nil {
+		t.Errorf("expected to verify: %v", err)
+	}
+	broken := bytes.Replace(single, []byte("crc32=ded29f4f"), []byte("crc32=ded29f40"), 1)
+	if err := VerifyOne(bytes.NewReader(broken)); err == nil {
+		t.Errorf("expected a crc mismatch to fail")
+	}
+	if err := VerifyOne(strings.NewReader("no yenc here\r\n")); err == nil {
+		t.Errorf("expected input without yenc to fail")
+	}
+}
+
+func BenchmarkVerifyOne(b *testing.B) {
+	data := loadFixture(b, "singlepart_test.yenc")
+	b.Run("Decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewDecoder(bytes.NewReader(data), nil, nil, 1).Decode(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("VerifyOne", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := VerifyOne(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestQuotedName(t *testing.T) {
+	for file, want := range map[string]string{
+		"singlepart_test.yenc": "testfile.txt",
+		"quotedname_test.yenc": `my "test" file.txt`,
+	} {
+		data := loadFixture(t, file)
+		part, err := NewDecoder(nil, data, nil, -1).Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if part.Name != want {
+			t.Errorf("%s: expected name %q got %q", file, want, part.Name)
+		}
+		header, _, _ := bytes.Cut(data, []byte("\r\n"))
+		if got := headerName(ParseHeaders(header)); got != want {
+			t.Errorf("%s: expected ParseHeaders name %q got %q", file, want, got)
+		}
+		// ParseHeaders keeps name= but strips the quotes
+		if got := strings.TrimSpace(ParseHeaders(header)["name"]); got != "name="+want {
+			t.Errorf("%s: expected ParseHeaders name %q got %q", file, "name="+want, got)
+		}
+	}
+}
+
+func TestPartString(t *testing.T) {
+	f, err := os.Open("singlepart_test.yenc")
+	if err != nil {
+		t.Fatal("could not open singlepart_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := NewDecoder(f, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	want := `yenc.Part{Number=0 Total=0 Name="testfile.txt" HeaderSize=584 Size=584 Begin=0 End=0 Crc32=ded29f4f computed=ded29f4f Body=584}`
+	if got := part.String(); got != want {
+		t.Errorf("expected %s got %s", want, got)
+	}
+}
+
+func TestLinesLongerThanCols(t *testing.T) {
+	data := make([]byte, 2000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "wide.bin", Line: 300}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	// the header claims a narrower width than the body uses
+	article := bytes.Replace(buf.Bytes(), []byte("line=300"), []byte("line=128"), 1)
+	result, err := NewDecoder(nil, article, nil, -1).DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !bytes.Equal(result.Part.Body, data) {
+		t.Errorf("expected lines wider than line= to decode")
+	}
+	if result.Stats.MaxLine < 300 {
+		t.Errorf("expected the longest line to be at least 300 got %d", result.Stats.MaxLine)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Kind != WarnLineLength {
+		t.Errorf("expected only a line length warning got %v", result.Warnings)
+	}
+}
+
+func TestNormalizedName(t *testing.T) {
+	for _, tc := range []struct {
+		name, safe, normalized string
+	}{
+		{"file.bin", "file.bin", "file.bin"},
+		{`dir\sub\file.bin`, "file.bin", filepath.Join("dir", "sub", "file.bin")},
+		{"dir/sub//file.bin", "file.bin", filepath.Join("dir", "sub", "file.bin")},
+		{`..\..\etc/passwd`, "passwd", filepath.Join("etc", "passwd")},
+		{"/abs/a\x00b.bin", "ab.bin", filepath.Join("abs", "ab.bin")},
+		{`..\`, "unnamed.bin", "unnamed.bin"},
+	} {
+		part := &Part{Name: tc.name}
+		if got := part.SafeName(); got != tc.safe {
+			t.Errorf("%q: expected SafeName %q got %q", tc.name, tc.safe, got)
+		}
+		if got := part.NormalizedName(); got != tc.normalized {
+			t.Errorf("%q: expected NormalizedName %q got %q", tc.name, tc.normalized, got)
+		}
+		if part.Name != tc.name {
+			t.Errorf("expected Name to stay %q got %q", tc.name, part.Name)
+		}
+	}
+}
+
+func TestValidateFull(t *testing.T) {
+	full := loadFixture(t, "multipart_full_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	broken := bytes.Replace(full, []byte("crc32=4c251c57"), []byte("crc32=4c251c50"), 1)
+	single := loadFixture(t, "singlepart_test.yenc")
+	pcrcOnly := bytes.Replace(single, []byte(" crc32="), []byte(" pcrc32="), 1)
+	for _, tc := range []struct {
+		data  []byte
+		mode  ValidateMode
+		fails bool
+	}{
+		{broken, ValidateAuto, true},
+		{broken, ValidateAlways, true},
+		{broken, ValidateNever, false},
+		// only part 1 without crc32=
+		{multi, ValidateAuto, false},
+		{multi, ValidateAlways, true},
+		{single, ValidateAlways, false},
+		// a single part without crc32= of the whole file
+		{pcrcOnly, ValidateAuto, false},
+		{pcrcOnly, ValidateAlways, true},
+	} {
+		decoder := NewDecoder(nil, tc.data, nil, -1)
+		decoder.ValidateFull = tc.mode
+		if _, err := decoder.Decode(); (err != nil) != tc.fails {
+			t.Errorf("mode %d: expected failure %t got %v", tc.mode, tc.fails, err)
+		}
+		decoder = NewDecoder(nil, tc.data, nil, -1)
+		decoder.ValidateFull = tc.mode
+		if _, err := decoder.DecodeAll(); (err != nil) != tc.fails {
+			t.Errorf("DecodeAll mode %d: expected failure %t got %v", tc.mode, tc.fails, err)
+		}
+	}
+}
+
+func TestChunkSize(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	contiguous, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	decoder := NewDecoder(nil, data, nil, -1)
+	decoder.ChunkSize = 1000
+	chunked, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode chunked: %v", err.Error())
+	}
+	for i, part := range chunked {
+		if part.Body != nil || len(part.Chunks) != 4 {
+			t.Errorf("part %d: expected 4 chunks and no Body got %d chunks", part.Number, len(part.Chunks))
+		}
+		for _, chunk := range part.Chunks[:len(part.Chunks)-1] {
+			if len(chunk) != decoder.ChunkSize {
+				t.Errorf("part %d: expected full chunks got %d bytes", part.Number, len(chunk))
+			}
+		}
+		var buf bytes.Buffer
+		if n, err := part.WriteTo(&buf); err != nil || n != part.Size {
+			t.Fatalf("part %d: expected to write %d bytes got %d %v", part.Number, part.Size, n, err)
+		}
+		if !bytes.Equal(buf.Bytes(), contiguous[i].Body) {
+			t.Errorf("part %d: expected chunked output to match Body", part.Number)
+		}
+	}
+}
+
+func TestArticleHeaders(t *testing.T) {
+	data := loadFixture(t, "headers_test.yenc")
+	lines := fixtureLines(data)
+	// the =ybegin line in the article headers must not be decoded
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, data, nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if part.Name != "testfile.txt" || part.Size != 584 {
+			t.Errorf("expected testfile.txt got %s", part)
+		}
+	}
+	single := loadFixture(t, "singlepart_test.yenc")
+	for _, prefix := range []string{
+		// no blank line between the headers and =ybegin
+		"Name: x\r\n",
+		"From: poster@example.com\r\nSubject: testfile.txt\r\n",
+		// looks like a header but no header block follows
+		"File: foo.rar\r\nsome text\r\n",
+		"http://example.com/post\r\n",
+		// a =ybegin in the headers right before the blank line
+		"Subject: a\r\n=ybegin line=128 size=10 name=fake.bin\r\n\r\n",
+	} {
+		article := append([]byte(prefix), single...)
+		lines := fixtureLines(article)
+		for _, decoder := range []*Decoder{
+			NewDecoder(nil, article, nil, -1),
+			NewDecoder(nil, nil, lines, -1),
+		} {
+			part, err := decoder.Decode()
+			if err != nil {
+				t.Fatalf("%q: expected to decode: %v", prefix, err.Error())
+			}
+			if part.Name != "testfile.txt" || part.Size != 584 {
+				t.Errorf("%q: expected testfile.txt got %s", prefix, part)
+			}
+		}
+	}
+}
+
+func TestVerifyDir(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	broken := filepath.Join(t.TempDir(), "broken.yenc")
+	if err := os.WriteFile(broken, bytes.Replace(single, []byte("crc32=ded29f4f"), []byte("crc32=ded29f40"), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"singlepart_test.yenc", "multipart_test.yenc", "multipart_full_test.yenc", broken, "missing_test.yenc"}
+	results := VerifyDir(context.Background(), paths, 2)
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results got %d", len(paths), len(results))
+	}
+	for _, path := range paths[:3] {
+		if results[path] != nil {
+			t.Errorf("expected %s to verify got %v", path, results[path])
+		}
+	}
+	if results[broken] == nil || !errors.Is(results["missing_test.yenc"], os.ErrNotExist) {
+		t.Errorf("expected broken and missing files to fail got %v and %v", results[broken], results["missing_test.yenc"])
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for path, err := range VerifyDir(ctx, paths, 2) {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %s to be cancelled got %v", path, err)
+		}
+	}
+}
+
+func TestTruncatedBeforeTrailer(t *testing.T) {
+	f, err := os.Open("truncated_test.yenc")
+	if err != nil {
+		t.Fatal("could not open truncated_test.yenc for testing")
+	}
+	defer f.Close()
+	_, err = NewDecoder(f, nil, nil, -1).Decode()
+	if !errors.Is(err, ErrTruncatedBeforeTrailer) {
+		t.Fatalf("expected ErrTruncatedBeforeTrailer got %v", err)
+	}
+	if !strings.Contains(err.Error(), "124 of 584 bytes missing") {
+		t.Errorf("expected the shortfall in %q", err)
+	}
+}
+
+func TestNameWithEquals(t *testing.T) {
+	const name = "a = b (1) name=x.bin"
+	data := loadFixture(t, "equalsname_test.yenc")
+	parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	header, _, _ := bytes.Cut(data, []byte("\r\n"))
+	if got := headerName(ParseHeaders(header)); got != name {
+		t.Errorf("expected ParseHeaders name %q got %q", name, got)
+	}
+	if got := ParseHeaders(header)["size"]; got != "1000" {
+		t.Errorf("expected ParseHeaders size 1000 got %q", got)
+	}
+	var buf bytes.Buffer
+	for _, part := range parts {
+		if part.Name != name {
+			t.Errorf("expected name %q got %q", name, part.Name)
+		}
+		if err := part.Encode(&buf, nil); err != nil {
+			t.Fatalf("expected to encode: %v", err)
+		}
+	}
+	again, err := NewDecoder(&buf, nil, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode re-encoded parts: %v", err.Error())
+	}
+	if len(again) != 2 || again[0].Name != name || again[1].Name != name {
+		t.Errorf("expected name %q to survive the round trip", name)
+	}
+}
+
+func TestExtraHash(t *testing.T) {
+	f, err := os.Open("multipart_full_test.yenc")
+	if err != nil {
+		t.Fatal("could not open multipart_full_test.yenc for testing")
+	}
+	defer f.Close()
+	decoder := NewDecoder(f, nil, nil, -1)
+	decoder.ExtraHash = sha256.New()
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	for _, part := range parts {
+		if want := sha256.Sum256(part.Body); !bytes.Equal(part.ExtraSum, want[:]) {
+			t.Errorf("part %d: expected sha256 %x got %x", part.Number, want, part.ExtraSum)
+		}
+	}
+}
+
+func TestDecodeSliceAt(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	other := bytes.Replace(single, []byte("name=testfile.txt"), []byte("name=other.txt"), 1)
+	blob := append(append([]byte{}, single...), other...)
+	first, n, err := DecodeSliceAt(blob)
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if n != len(single) || first.Name != "testfile.txt" {
+		t.Fatalf("expected to consume %d bytes of testfile.txt got %d of %s", len(single), n, first.Name)
+	}
+	second, m, err := DecodeSliceAt(blob[n:])
+	if err != nil {
+		t.Fatalf("expected to decode the second article: %v", err.Error())
+	}
+	if n+m != len(blob) || second.Name == first.Name {
+		t.Errorf("expected to consume the rest of the blob got %d of %d", n+m, len(blob))
+	}
+}
+
+func TestNoFinalNewline(t *testing.T) {
+	f, err := os.Open("nofinalnewline_test.yenc")
+	if err != nil {
+		t.Fatal("could not open nofinalnewline_test.yenc for testing")
+	}
+	defer f.Close()
+	part, err := NewDecoder(f, nil, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Size != 584 || len(part.Body) != 584 {
+		t.Errorf("expected 584 bytes got %d", len(part.Body))
+	}
+	// a body line cut off at EOF is decoded, then the missing =yend fails
+	single := loadFixture(t, "singlepart_test.yenc")
+	cut := single[:bytes.LastIndex(single, []byte("\r\n=yend"))]
+	_, err = NewDecoder(nil, cut, nil, -1).Decode()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+}
+
+func TestDecodeSinglePart(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	part, err := DecodeSinglePart(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Number != 1 || part.Size != 3334 {
+		t.Errorf("expected part 1 got %s", part)
+	}
+	// the last part alone carries crc32= of the whole file
+	third := data[bytes.Index(data, []byte("=ybegin part=3")):]
+	if part, err = DecodeSinglePart(bytes.NewReader(third)); err != nil {
+		t.Fatalf("expected to decode part 3: %v", err.Error())
+	}
+	if part.Number != 3 || part.Total != 3 || part.Begin != 6669 || part.End != 10000 {
+		t.Errorf("expected part 3 got %s", part)
+	}
+}
+
+func TestUseScanner(t *testing.T) {
+	for _, file := range []string{"singlepart_test.yenc", "multipart_full_test.yenc", "nofinalnewline_test.yenc", "headers_test.yenc",
+		// peek at the line after =ybegin
+		"nopartline_test.yenc", "wrappedheader_test.yenc"} {
+		data := loadFixture(t, file)
+		want, err := NewDecoder(bytes.NewReader(data), nil, nil, -1).DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode %s: %v", file, err)
+		}
+		decoder := NewDecoder(bytes.NewReader(data), nil, nil, -1)
+		decoder.UseScanner = true
+		got, err := decoder.DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode %s with scanner: %v", file, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d parts got %d", file, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].String() != want[i].String() || !bytes.Equal(got[i].Body, want[i].Body) {
+				t.Errorf("%s: expected %s got %s", file, want[i], got[i])
+			}
+		}
+	}
+	single := loadFixture(t, "singlepart_test.yenc")
+	decoder := NewDecoder(bytes.NewReader(single), nil, nil, -1)
+	decoder.UseScanner = true
+	decoder.ScannerMax = 100
+	if _, err := decoder.Decode(); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("expected ErrLineTooLong got %v", err)
+	}
+	// Buffered takes back what the scanner read ahead
+	trailer := []byte("trailing data\r\n")
+	decoder = NewDecoder(bytes.NewReader(append(slices.Clone(single), trailer...)), nil, nil, 1)
+	decoder.UseScanner = true
+	if _, err := decoder.Decode(); err != nil {
+		t.Fatalf("expected to decode with scanner: %v", err)
+	}
+	if rest, err := io.ReadAll(decoder.Buffered()); err != nil || !bytes.Equal(rest, trailer) {
+		t.Errorf("expected %q after the part got %q err=%v", trailer, rest, err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	// drop part 2
+	second := bytes.Index(data, []byte("=ybegin part=2"))
+	third := bytes.Index(data, []byte("=ybegin part=3"))
+	partial := append(append([]byte{}, data[:second]...), data[third:]...)
+	decoder := NewDecoder(nil, partial, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	s := decoder.Summary()
+	if s.Name != "random.bin" || s.Total != 3 || fmt.Sprint(s.Present) != "[1 3]" || fmt.Sprint(s.Missing) != "[2]" {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s.TotalBytes != 3334+3332 || s.AllValid {
+		t.Errorf("expected 6666 bytes and not valid got %d %t", s.TotalBytes, s.AllValid)
+	}
+	if missing := decoder.MissingParts(); fmt.Sprint(missing) != "[2]" {
+		t.Errorf("expected part 2 missing got %v", missing)
+	}
+	decoder = NewDecoder(nil, data, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if s := decoder.Summary(); !s.AllValid || len(s.Missing) != 0 || s.TotalBytes != 10000 {
+		t.Errorf("expected a complete file got %+v", s)
+	}
+}
+
+func TestSuspectUnescapedNewline(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	lines := bytes.SplitAfter(single, []byte("\r\n"))
+	// a byte of the third line was written as a raw LF
+	lines[2] = append(append(append([]byte{}, lines[2][:50]...), '\n'), lines[2][51:]...)
+	_, err := NewDecoder(nil, bytes.Join(lines, nil), nil, -1).Decode()
+	if err == nil || !strings.Contains(err.Error(), "unescaped newline") {
+		t.Errorf("expected the error to mention an unescaped newline got %v", err)
+	}
+	// a split which happens to keep size and crc intact is a warning
+	lines = bytes.SplitAfter(single, []byte("\r\n"))
+	lines[2] = append(append(append([]byte{}, lines[2][:50]...), '\n'), lines[2][50:]...)
+	result, err := NewDecoder(nil, bytes.Join(lines, nil), nil, -1).DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !result.Part.SuspectUnescapedNewline || len(result.Warnings) != 1 || result.Warnings[0].Kind != WarnUnescapedNewline {
+		t.Errorf("expected a WarnUnescapedNewline got %v", result.Warnings)
+	}
+	// a short last line and a body narrower than line= are fine
+	for _, data := range [][]byte{single, bytes.Replace(single, []byte("line=128"), []byte("line=256"), 1)} {
+		result, err := NewDecoder(nil, data, nil, -1).DecodeResult()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if result.Part.SuspectUnescapedNewline || len(result.Warnings) != 0 {
+			t.Errorf("expected no warnings got %v", result.Warnings)
+		}
+	}
+}
+
+func TestNewDecoderFromBufio(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	br := bufio.NewReader(bytes.NewReader(single))
+	// sniff before handing the reader over: the data is buffered now
+	head, err := br.Peek(SniffLen / 8)
+	if err != nil || !IsYEnc(head) {
+		t.Fatalf("expected to sniff yenc: %v", err)
+	}
+	if br.Buffered() != len(single) {
+		t.Fatalf("expected the whole fixture to be buffered got %d", br.Buffered())
+	}
+	part, err := NewDecoderFromBufio(br, 1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Name != "testfile.txt" || len(part.Body) != 584 {
+		t.Errorf("expected testfile.txt got %s", part)
+	}
+}
+
+func TestInterleavedFiles(t *testing.T) {
+	a := loadFixture(t, "multipart_full_test.yenc")
+	b := bytes.ReplaceAll(a, []byte("name=random.bin"), []byte("name=other.bin"))
+	// split into articles, one per part
+	split := func(data []byte) [][]byte {
+		var articles [][]byte
+		for _, art := range bytes.Split(data, []byte("=ybegin ")) {
+			if len(art) > 0 {
+				articles = append(articles, append([]byte("=ybegin "), art...))
+			}
+		}
+		return articles
+	}
+	var stream []byte
+	as, bs := split(a), split(b)
+	for i := range as {
+		stream = append(append(stream, as[i]...), bs[i]...)
+	}
+	decoder := NewDecoder(nil, stream, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	files := decoder.PartsByName()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files got %d", len(files))
+	}
+	for name, parts := range files {
+		if len(parts) != 3 || parts[0].Number != 1 || parts[2].Number != 3 {
+			t.Errorf("%s: expected parts 1 to 3 in order got %d parts", name, len(parts))
+		}
+		if err := CheckContiguous(parts); err != nil {
+			t.Errorf("%s: expected contiguous parts got %v", name, err)
+		}
+	}
+}
+
+func TestErrorLatched(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	broken := bytes.Replace(single, []byte("crc32=ded29f4f"), []byte("crc32=ded29f40"), 1)
+	decoder := NewDecoder(nil, append(append([]byte{}, broken...), single...), nil, 1)
+	_, first := decoder.Decode()
+	if first == nil {
+		t.Fatalf("expected a crc error")
+	}
+	// the second article is fine but the decoder has failed
+	if _, err := decoder.Decode(); err == nil || err.Error() != first.Error() {
+		t.Errorf("expected the latched error %v got %v", first, err)
+	}
+	decoder.Reset()
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode after Reset: %v", err.Error())
+	}
+	if part.Name != "testfile.txt" || len(part.Body) != 584 {
+		t.Errorf("expected testfile.txt got %s", part)
+	}
+}
+
+func TestSplitArticles(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	var blob []byte
+	blob = append(blob, "junk before\r\n\r\n"...)
+	blob = append(blob, single...)
+	blob = append(blob, "-- \r\nsignature\r\n"...)
+	blob = append(blob, multi...)
+	articles, err := SplitArticles(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatalf("expected to split: %v", err)
+	}
+	if len(articles) != 4 || !bytes.Equal(articles[0], single) {
+		t.Fatalf("expected 4 articles starting with the single part got %d", len(articles))
+	}
+	for i, article := range articles {
+		if _, err := NewDecoder(nil, article, nil, 1).Decode(); err != nil {
+			t.Errorf("article %d: expected to decode: %v", i, err)
+		}
+	}
+	_, err = SplitArticles(bytes.NewReader(single[:len(single)-40]))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for a cut off article got %v", err)
+	}
+}
+
+func TestWantParts(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	decoder := NewDecoder(nil, data, nil, -1)
+	decoder.WantParts = map[int]bool{2: true}
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(parts) != 1 || parts[0].Number != 2 || parts[0].Begin != 3335 {
+		t.Fatalf("expected only part 2 got %d parts", len(parts))
+	}
+	// the crc32= of the skipped part 3 is not recorded
+	if crc, ok := decoder.FinalCRC(); ok || crc != 0 {
+		t.Errorf("expected no final crc from a skipped part got %08x ok=%t", crc, ok)
+	}
+	// skipped parts are not processed and can be decoded later
+	decoder.WantParts = nil
+	decoder.SetBytes(data)
+	if parts, err = decoder.DecodeAll(); err == nil {
+		t.Fatalf("expected part 2 to be already processed")
+	}
+	decoder.Reset()
+	decoder.SetBytes(data)
+	decoder.WantParts = map[int]bool{1: true, 3: true}
+	if parts, err = decoder.DecodeAll(); err != nil || len(parts) != 2 || parts[1].Number != 3 {
+		t.Errorf("expected parts 1 and 3 got %d %v", len(parts), err)
+	}
+}
+
+func TestMissingPartHeader(t *testing.T) {
+	data := loadFixture(t, "noypart_test.yenc")
+	lines := fixtureLines(data)
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, data, nil, -1),
+		NewDecoder(nil, data[:bytes.Index(data, []byte("=yend"))], nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		if _, err := decoder.Decode(); !errors.Is(err, ErrMissingPartHeader) {
+			t.Errorf("expected ErrMissingPartHeader got %v", err)
+		}
+	}
+}
+
+func TestDecodeConn(t *testing.T) {
+	article := loadFixture(t, "article_test.yenc")
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go server.Write(article)
+	part, err := DecodeConn(client, time.Now().Add(5*time.Second))
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Name != "dots.bin" || len(part.Body) != 400 {
+		t.Errorf("expected dots.bin got %s", part)
+	}
+	// the server does not send anything
+	_, err = DecodeConn(client, time.Now().Add(50*time.Millisecond))
+	if !errors.Is(err, ErrReadTimeout) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected ErrReadTimeout got %v", err)
+	}
+}
+
+func TestSkipBadLines(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	lines := bytes.SplitAfter(single, []byte("\r\n"))
+	lines[2] = append([]byte{}, lines[2]...)
+	lines[2][10] = 0x00
+	corrupt := bytes.Join(lines, nil)
+	// without SkipBadLines the line is decoded and the crc fails
+	if _, err := NewDecoder(nil, corrupt, nil, -1).Decode(); !errors.Is(err, ErrCRCMismatch) {
+		t.Fatalf("expected ErrCRCMismatch got %v", err)
+	}
+	decoder := NewDecoder(nil, corrupt, nil, -1)
+	decoder.SkipBadLines = true
+	result, err := decoder.DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to salvage: %v", err.Error())
+	}
+	if fmt.Sprint(result.Part.BadLines) != "[3]" || len(result.Warnings) == 0 || result.Warnings[0].Kind != WarnBadLines {
+		t.Errorf("expected bad line 3 and a warning got %v %v", result.Part.BadLines, result.Warnings)
+	}
+	// everything but the bad line is there
+	good, _ := NewDecoder(nil, single, nil, -1).Decode()
+	first := len((&Decoder{}).decode(bytes.TrimRight(lines[1], "\r\n")))
+	if !bytes.HasPrefix(good.Body, result.Part.Body[:first]) || !bytes.HasSuffix(good.Body, result.Part.Body[first:]) {
+		t.Errorf("expected the other lines to be recovered")
+	}
+}
+
+func TestWrappedHeader(t *testing.T) {
+	data := loadFixture(t, "wrappedheader_test.yenc")
+	lines := fixtureLines(data)
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, data, nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if part.Name != "joystick.jpg" || part.HeaderSize != 19338 || part.End != 11250 {
+			t.Errorf("expected joystick.jpg got %s", part)
+		}
+	}
+}
+
+func TestWrappedHeaderPipe(t *testing.T) {
+	// shorter than the read buffer
+	data := []byte("=ybegin line=128 size=3\r\nname=abc.bin\r\nklm\r\n=yend size=3 crc32=" + CRC32Hex([]byte("ABC")) + "\r\n")
+	for _, scanner := range []bool{false, true} {
+		// the writer stays open after the part:
+		// looking for the continuation must not wait for more input
+		pr, pw := io.Pipe()
+		go pw.Write(data)
+		decoder := NewDecoder(pr, nil, nil, 1)
+		decoder.UseScanner = scanner
+		done := make(chan error, 1)
+		go func() {
+			_, err := decoder.Decode()
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("scanner=%t: expected to decode: %v", scanner, err.Error())
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("scanner=%t: decode blocked on the open pipe", scanner)
+		}
+		pw.Close()
+	}
+}
+
+func TestCurrentPart(t *testing.T) {
+	data := loadFixture(t, "multipart_test.yenc")
+	decoder := NewDecoder(nil, data, nil, -1)
+	if n := decoder.CurrentPart(); n != 0 {
+		t.Errorf("expected 0 before decoding got %d", n)
+	}
+	for _, err := range decoder.All() {
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if n := decoder.CurrentPart(); n != 1 {
+			t.Errorf("expected part 1 got %d", n)
+		}
+	}
+}
+
+func TestInconsistentTotal(t *testing.T) {
+	data := loadFixture(t, "inconsistenttotal_test.yenc")
+	decoder := NewDecoder(nil, data, nil, -1)
+	if _, err := decoder.DecodeAll(); !errors.Is(err, ErrInconsistentTotal) {
+		t.Fatalf("expected ErrInconsistentTotal got %v", err)
+	}
+}
+
+func TestDecodePipe(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	pr, pw := io.Pipe()
+	go func() {
+		// split writes inside CRLF, escapes and the =y markers
+		rest := data
+		for i := 0; len(rest) > 0; i++ {
+			n := min(len(rest), 1+i%13)
+			if _, err := pw.Write(rest[:n]); err != nil {
+				return
+			}
+			rest = rest[n:]
+		}
+		pw.Close()
+	}()
+	decoder := NewDecoder(pr, nil, nil, -1)
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	want, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("expected %d parts got %d", len(want), len(parts))
+	}
+	for i := range parts {
+		if !bytes.Equal(parts[i].Body, want[i].Body) {
+			t.Errorf("part %d differs when read from a pipe", i+1)
+		}
+	}
+	if err := decoder.next(); err != io.EOF {
+		t.Errorf("expected io.EOF after the writer closed got %v", err)
+	}
+}
+
+func TestVerifyAgainst(t *testing.T) {
+	data := loadFixture(t, "singlepart_test.yenc")
+	part, err := NewDecoder(nil, data, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if err := part.VerifyAgainst(CRC32(part.Body)); err != nil {
+		t.Errorf("expected crc of the body to verify: %v", err)
+	}
+	if err := part.VerifyAgainst(part.Crc32 ^ 1); err == nil {
+		t.Error("expected a wrong crc to fail")
+	}
+	if err := new(Part).VerifyAgainst(0); err == nil {
+		t.Error("expected an undecoded part to fail")
+	}
+}
+
+func TestHeaderLastLine(t *testing.T) {
+	data := loadFixture(t, "lastheader_test.yenc")
+	// the header is found, the article ends right after it
+	decoder := NewDecoder(bytes.NewReader(data), nil, nil, -1)
+	decoder.part = new(Part)
+	err := decoder.readHeader()
+	if err != nil {
+		t.Fatalf("expected to read the header: %v", err.Error())
+	}
+	if decoder.part.Name != "joystick.jpg" || decoder.part.HeaderSize != 19338 {
+		t.Errorf("expected header of joystick.jpg got %s", decoder.part)
+	}
+	if _, err := NewDecoder(bytes.NewReader(data), nil, nil, -1).Decode(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+}
+
+func TestChunkFunc(t *testing.T) {
+	data := loadFixture(t, "singlepart_test.yenc")
+	want, err := NewDecoder(nil, data, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	var buf bytes.Buffer
+	decoder := NewDecoder(bytes.NewReader(data), nil, nil, -1)
+	decoder.ChunkFunc = func(p *Part, b []byte) error {
+		buf.Write(b)
+		return nil
+	}
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Body != nil || !bytes.Equal(buf.Bytes(), want.Body) {
+		t.Errorf("expected %d streamed bytes and no body got %d and %d", len(want.Body), buf.Len(), len(part.Body))
+	}
+	buf.Reset()
+	if err := want.ForEachChunk(func(b []byte) error {
+		buf.Write(b)
+		return nil
+	}); err != nil || !bytes.Equal(buf.Bytes(), want.Body) {
+		t.Errorf("expected ForEachChunk to return the body err=%v", err)
+	}
+	// the helpers reading the body have nothing to read
+	if err := part.ForEachChunk(func(b []byte) error { return nil }); !errors.Is(err, ErrBodyStreamed) {
+		t.Errorf("expected ErrBodyStreamed from ForEachChunk got %v", err)
+	}
+	if _, err := part.WriteTo(io.Discard); !errors.Is(err, ErrBodyStreamed) {
+		t.Errorf("expected ErrBodyStreamed from WriteTo got %v", err)
+	}
+	if got := part.BodyString(); got != "" {
+		t.Errorf("expected an empty BodyString got %d bytes", len(got))
+	}
+	if _, err := part.BodyStringCharset(latin1{}); !errors.Is(err, ErrBodyStreamed) {
+		t.Errorf("expected ErrBodyStreamed from BodyStringCharset got %v", err)
+	}
+	if _, err := Concat([]*Part{part}); !errors.Is(err, ErrBodyStreamed) {
+		t.Errorf("expected ErrBodyStreamed from Concat got %v", err)
+	}
+	if err := ReconstructToMmap(filepath.Join(t.TempDir(), "out"), part.Size, []*Part{part}); !errors.Is(err, ErrBodyStreamed) {
+		t.Errorf("expected ErrBodyStreamed from ReconstructToMmap got %v", err)
+	}
+
+	stop := errors.New("stop")
+	decoder = NewDecoder(bytes.NewReader(data), nil, nil, -1)
+	decoder.ChunkFunc = func(p *Part, b []byte) error {
+		return stop
+	}
+	if _, err := decoder.Decode(); !errors.Is(err, stop) {
+		t.Errorf("expected the ChunkFunc error got %v", err)
+	}
+}
+
+func TestUppercaseHex(t *testing.T) {
+	defer func() { UppercaseHex = false }()
+	data := []byte("yenc")
+	lower := CRC32Hex(data)
+	UppercaseHex = true
+	if upper := CRC32Hex(data); upper != strings.ToUpper(lower) {
+		t.Errorf("expected %s got %s", strings.ToUpper(lower), upper)
+	}
+	part := &Part{crcHash: crc32.NewIEEE()}
+	part.crcHash.Write(data)
+	if err := part.VerifyAgainst(0xabcdef01); err == nil || !strings.Contains(err.Error(), "ABCDEF01") {
+		t.Errorf("expected uppercase crc in error got %v", err)
+	}
+}
+
+func TestSingleShot(t *testing.T) {
+	data := loadFixture(t, "singlepart_test.yenc")
+	// a megabyte of trailing junk after =yend
+	junk := bytes.Repeat([]byte("PAR2\x00PKT junk\r\n"), 1<<16)
+	cr := &countingReader{r: io.MultiReader(bytes.NewReader(data), bytes.NewReader(junk))}
+	decoder := NewDecoder(cr, nil, nil, 1)
+	decoder.SingleShot = true
+	if _, err := decoder.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part, err := decoder.Decode(); part != nil || err != io.EOF {
+		t.Errorf("expected no part and io.EOF from Decode got %v", err)
+	}
+	if part, err := decoder.DecodeSlice(); part != nil || err != io.EOF {
+		t.Errorf("expected no part and io.EOF from DecodeSlice got %v", err)
+	}
+	if parts, err := decoder.DecodeAll(); parts != nil || err != io.EOF {
+		t.Errorf("expected no parts and io.EOF from DecodeAll got %v", err)
+	}
+	if parts, err := decoder.DecodeN(1); parts != nil || err != io.EOF {
+		t.Errorf("expected no parts and io.EOF from DecodeN got %v", err)
+	}
+	if err := decoder.next(); err != io.EOF {
+		t.Errorf("expected io.EOF got %v", err)
+	}
+	if cr.n >= len(data)+len(junk)/2 {
+		t.Errorf("expected the junk not to be read, read %d of %d bytes", cr.n, len(data)+len(junk))
+	}
+}
+
+func TestLeadingBOM(t *testing.T) {
+	data := loadFixture(t, "bom_test.yenc")
+	want := loadFixture(t, "singlepart_test.yenc")
+	wantPart, err := NewDecoder(nil, want, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	lines := fixtureLines(data)
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, data, nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if part.Name != wantPart.Name || !bytes.Equal(part.Body, wantPart.Body) {
+			t.Errorf("expected %s got %s", wantPart, part)
+		}
+	}
+}
+
+func TestRawBeginEnd(t *testing.T) {
+	data := loadFixture(t, "comment_test.yenc")
+	part, err := NewDecoder(nil, data, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\r\n")
+	if part.RawBegin != lines[0] {
+		t.Errorf("expected RawBegin %q got %q", lines[0], part.RawBegin)
+	}
+	if last := lines[len(lines)-1]; part.RawEnd != last {
+		t.Errorf("expected RawEnd %q got %q", last, part.RawEnd)
+	}
+}
+
+func TestLenientNumbers(t *testing.T) {
+	for _, fn := range []string{"sizecomma_test.yenc", "sizespace_test.yenc"} {
+		data := loadFixture(t, fn)
+		if _, err := NewDecoder(nil, data, nil, -1).Decode(); err == nil {
+			t.Errorf("%s: expected strict numbers to fail", fn)
+		}
+		decoder := NewDecoder(nil, data, nil, -1)
+		decoder.LenientNumbers = true
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", fn, err.Error())
+		}
+		if part.HeaderSize != 12345 || part.Size != 12345 || len(part.Body) != 12345 {
+			t.Errorf("%s: expected 12345 bytes got %s", fn, part)
+		}
+	}
+}
+
+func TestMaxEscapeRatio(t *testing.T) {
+	// 0x13 encodes to '=' and has to be escaped every time
+	data := bytes.Repeat([]byte{0x13}, 4096)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "escapes.bin"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	decoder := NewDecoder(bytes.NewReader(buf.Bytes()), nil, nil, -1)
+	decoder.MaxEscapeRatio = 0.1
+	if _, err := decoder.Decode(); !errors.Is(err, ErrTooManyEscapes) {
+		t.Errorf("expected ErrTooManyEscapes got %v", err)
+	}
+	// a short part is checked at its end
+	var short bytes.Buffer
+	if err := Encode(&short, data[:100], &EncodeOptions{Name: "escapes.bin"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, short.Bytes(), nil, -1),
+		NewDecoder(nil, nil, fixtureLines(short.Bytes()), -1),
+	} {
+		decoder.MaxEscapeRatio = 0.1
+		if _, err := decoder.Decode(); !errors.Is(err, ErrTooManyEscapes) {
+			t.Errorf("expected ErrTooManyEscapes for a short part got %v", err)
+		}
+	}
+	// escapes at the start of otherwise plain data
+	mixed := append(bytes.Clone(data[:100]), bytes.Repeat([]byte{'a' - 42}, 20000)...)
+	var plain bytes.Buffer
+	if err := Encode(&plain, mixed, &EncodeOptions{Name: "mixed.bin"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	decoder = NewDecoder(nil, plain.Bytes(), nil, -1)
+	decoder.MaxEscapeRatio = 0.1
+	if _, err := decoder.Decode(); err != nil {
+		t.Errorf("expected to decode escapes at the start: %v", err.Error())
+	}
+	// random data stays well below
+	normal := loadFixture(t, "multipart_full_test.yenc")
+	decoder = NewDecoder(nil, normal, nil, -1)
+	decoder.MaxEscapeRatio = 0.1
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+}
+
+func TestDecodeExpectingSize(t *testing.T) {
+	data := loadFixture(t, "singlepart_test.yenc")
+	want, err := NewDecoder(nil, data, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	part, err := DecodeExpectingSize(bytes.NewReader(data), want.HeaderSize)
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !bytes.Equal(part.Body, want.Body) || int64(cap(part.Body)) != want.HeaderSize {
+		t.Errorf("expected %d preallocated bytes got len %d cap %d", want.HeaderSize, len(part.Body), cap(part.Body))
+	}
+	if _, err := DecodeExpectingSize(bytes.NewReader(data), want.HeaderSize+1); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch got %v", err)
+	}
+	// a bad index entry is not allocated
+	if _, err := DecodeExpectingSize(bytes.NewReader(data), 1<<50); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch for a huge size got %v", err)
+	}
+	if _, err := DecodeExpectingSize(bytes.NewReader(data), -1); err == nil {
+		t.Error("expected an error for a negative size")
+	}
+}
+
+func TestDecoderFiles(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	decoder := NewDecoder(nil, append(append([]byte(nil), multi...), single...), nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	files := decoder.Files()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files got %d", len(files))
+	}
+	if f := files[0]; f.Name != "random.bin" || !f.Multipart || len(f.Parts) != 3 {
+		t.Errorf("expected 3 parts of random.bin first got %s multipart=%t with %d parts", f.Name, f.Multipart, len(f.Parts))
+	}
+	if f := files[1]; f.Name != "testfile.txt" || f.Multipart || len(f.Parts) != 1 {
+		t.Errorf("expected single part testfile.txt second got %s multipart=%t with %d parts", f.Name, f.Multipart, len(f.Parts))
+	}
+}
+
+func TestTruncatedEscape(t *testing.T) {
+	data := loadFixture(t, "lonequals_test.yenc")
+	lines := fixtureLines(data)
+	for _, decoder := range []*Decoder{
+		NewDecoder(nil, data, nil, -1),
+		NewDecoder(nil, nil, lines, -1),
+	} {
+		var derr *DecodeError
+		if _, err := decoder.Decode(); !errors.Is(err, ErrTruncatedEscape) || !errors.As(err, &derr) {
+			t.Errorf("expected ErrTruncatedEscape in a DecodeError got %v", err)
+		}
+	}
+	// body lines without =yend end in the escape character
+	body := []*string{new(string), new(string)}
+	*body[0], *body[1] = "klm", "no="
+	var derr *DecodeError
+	_, err := DecodeBodyOnly(body, Header{Name: "abc.bin", Size: 5})
+	if !errors.Is(err, ErrTruncatedEscape) || !errors.As(err, &derr) || derr.Line != 1 {
+		t.Errorf("expected ErrTruncatedEscape on line 1 without =yend got %v", err)
+	}
+}
+
+func TestDecodeSegment(t *testing.T) {
+	data := loadFixture(t, "multipart_test.yenc")
+	if _, err := DecodeSegment(bytes.NewReader(data), "joystick.jpg", 1); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	var nameErr *ErrNameMismatch
+	if _, err := DecodeSegment(bytes.NewReader(data), "other.jpg", 1); !errors.As(err, &nameErr) || nameErr.Got != "joystick.jpg" {
+		t.Errorf("expected ErrNameMismatch got %v", err)
+	}
+	var partErr *ErrPartMismatch
+	if _, err := DecodeSegment(bytes.NewReader(data), "joystick.jpg", 2); !errors.As(err, &partErr) || partErr.Got != 1 {
+		t.Errorf("expected ErrPartMismatch got %v", err)
+	}
+	single := loadFixture(t, "singlepart_test.yenc")
+	if _, err := DecodeSegment(bytes.NewReader(single), "testfile.txt", 1); err != nil {
+		t.Errorf("expected a single part article to be segment 1: %v", err)
+	}
+}
+
+func TestOnBytes(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	decoder := NewDecoder(nil, data, nil, -1)
+	total, calls := 0, 0
+	decoder.OnBytes = func(n int) {
+		total += n
+		calls++
+	}
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if int64(total) != parts[0].HeaderSize || calls < len(parts) {
+		t.Errorf("expected %d bytes in several calls got %d in %d", parts[0].HeaderSize, total, calls)
+	}
+}
+
+func TestTrailerFieldOrder(t *testing.T) {
+	for _, fn := range []string{"yendpartfirst_test.yenc", "yendsizelast_test.yenc"} {
+		data := loadFixture(t, fn)
+		decoder := NewDecoder(nil, data, nil, -1)
+		parts, err := decoder.DecodeAll()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", fn, err.Error())
+		}
+		if len(parts) != 3 || parts[2].Size != 3332 || parts[2].Crc32 != 0x0bde115d {
+			t.Errorf("%s: expected 3 parts with the last of 3332 bytes got %d", fn, len(parts))
+		}
+		if crc, ok := decoder.FinalCRC(); !ok || crc != 0x4c251c57 {
+			t.Errorf("%s: expected final crc 4c251c57 got %08x", fn, crc)
+		}
+	}
+	// a wrong part= is reported after the whole line is parsed
+	decoder := &Decoder{part: &Part{Number: 1}}
+	err := decoder.parseTrailer("=yend part=2 size=5 pcrc32=0bde115d")
+	var order *ErrPartOutOfOrder
+	if !errors.As(err, &order) || decoder.part.Size != 5 || decoder.part.Crc32 != 0x0bde115d {
+		t.Errorf("expected ErrPartOutOfOrder with all fields parsed got %v size=%d", err, decoder.part.Size)
+	}
+}
+
+func TestDecodeBodyOnly(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	parts, err := NewDecoder(nil, data, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	want := parts[1]
+	// the body lines of part 2 without =ybegin, =ypart and =yend
+	var lines []*string
+	inPart := false
+	for _, line := range strings.Split(string(data), "\r\n") {
+		switch {
+		case strings.HasPrefix(line, "=ybegin part=2 "):
+			inPart = true
+		case strings.HasPrefix(line, "=y"):
+			if inPart && strings.HasPrefix(line, "=yend") {
+				inPart = false
+			}
+		case inPart:
+			lines = append(lines, &line)
+		}
+	}
+	hdr := Header{Name: want.Name, Line: 128, Size: want.HeaderSize, Part: 2, Total: 3,
+		Begin: want.Begin, End: want.End, Crc32: want.Crc32, CRCSet: true}
+	part, err := DecodeBodyOnly(lines, hdr)
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !bytes.Equal(part.Body, want.Body) || part.Number != 2 || part.Size != want.Size {
+		t.Errorf("expected %s got %s", want, part)
+	}
+	hdr.Crc32++
+	if _, err := DecodeBodyOnly(lines, hdr); err == nil {
+		t.Error("expected a wrong header crc to fail")
+	}
+}
+
+func TestValidated(t *testing.T) {
+	full := loadFixture(t, "multipart_full_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	single := loadFixture(t, "singlepart_test.yenc")
+	for i, tc := range []struct {
+		data      []byte
+		all       bool
+		skipCRC   bool
+		validated bool
+	}{
+		{full, false, false, true},
+		{full, true, false, true},
+		{full, true, true, false},
+		{single, false, false, true},
+		{single, false, true, false},
+		// part 1 of 3 only: the whole file was not checked
+		{multi, false, false, false},
+		{multi, true, false, false},
+	} {
+		decoder := NewDecoder(nil, tc.data, nil, -1)
+		decoder.SkipCRC = tc.skipCRC
+		var err error
+		if tc.all {
+			_, err = decoder.DecodeAll()
+		} else {
+			_, err = decoder.Decode()
+		}
+		if err != nil {
+			t.Fatalf("case %d: expected to decode: %v", i, err.Error())
+		}
+		if decoder.Validated() != tc.validated {
+			t.Errorf("case %d: expected Validated %t", i, tc.validated)
+		}
+	}
+	// a new input starts without the checks of the last one
+	decoder := NewDecoder(nil, full, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil || !decoder.Validated() {
+		t.Fatalf("expected to decode and validate: %v", err)
+	}
+	decoder.SetBytes(multi)
+	if decoder.Validated() || decoder.verified["random.bin"] {
+		t.Errorf("expected the checks of the last input to be cleared")
+	}
+}
+
+func TestVerifyPartsParallel(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	var parts []*Part
+	for _, keepRaw := range []bool{false, true} {
+		decoder := NewDecoder(nil, data, nil, -1)
+		decoder.KeepRawLines = keepRaw
+		decoded, err := decoder.DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		parts = append(parts, decoded...)
+	}
+	for len(parts) < 300 {
+		parts = append(parts, parts[:6]...)
+	}
+	broken := *parts[4]
+	broken.Crc32++
+	parts = append(parts, &broken)
+	errs := VerifyPartsParallel(parts, 8)
+	if len(errs) != len(parts) {
+		t.Fatalf("expected %d results got %d", len(parts), len(errs))
+	}
+	for i, err := range errs[:len(errs)-1] {
+		if err != nil {
+			t.Errorf("part %d: expected to verify: %v", i, err)
+		}
+	}
+	if errs[len(errs)-1] == nil {
+		t.Error("expected the broken part to fail")
+	}
+	// RawLines decode like the decoder which kept them
+	dots := bytes.Repeat([]byte{'.' - 42}, 1000)
+	var stuffed bytes.Buffer
+	if err := Encode(&stuffed, dots, &EncodeOptions{Name: "dots.bin", NNTPDotStuff: true}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	profile := &EscapeProfile{Offset: 17, EscapeOffset: 99, Escape: '#'}
+	var dialect bytes.Buffer
+	if err := Encode(&dialect, dots, &EncodeOptions{Name: "dialect.bin", Profile: profile}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	nntp := NewDecoder(bytes.NewReader(stuffed.Bytes()), nil, nil, 1)
+	nntp.NNTP = true
+	columnZero := NewDecoder(nil, bytes.TrimSuffix(stuffed.Bytes(), []byte(".\r\n")), nil, 1)
+	columnZero.ColumnZeroEscaping = true
+	custom := NewDecoder(nil, dialect.Bytes(), nil, 1)
+	custom.Profile = profile
+	for name, decoder := range map[string]*Decoder{"nntp": nntp, "column zero": columnZero, "profile": custom} {
+		decoder.KeepRawLines = true
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", name, err.Error())
+		}
+		if errs := VerifyPartsParallel([]*Part{part}, 1); errs[0] != nil {
+			t.Errorf("%s: expected the raw lines to verify: %v", name, errs[0])
+		}
+	}
+}
+
+func TestPreferHeaderSize(t *testing.T) {
+	data := loadFixture(t, "badtrailersize_test.yenc")
+	if _, err := NewDecoder(nil, data, nil, -1).Decode(); err == nil {
+		t.Fatal("expected the wrong trailer size to fail")
+	}
+	decoder := NewDecoder(nil, data, nil, -1)
+	decoder.PreferHeaderSize = true
+	result, err := decoder.DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part := result.Part; part.Size != 3000 || len(part.Body) != 3000 {
+		t.Errorf("expected 3000 bytes got %s", part)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Kind != WarnHeaderSize {
+		t.Errorf("expected a WarnHeaderSize warning got %v", result.Warnings)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	decoder := NewDecoder(nil, data, nil, -1)
+	if _, err := decoder.DecodeAll(); err != nil || decoder.Trace != nil {
+		t.Fatalf("expected no trace without TraceEnabled err=%v", err)
+	}
+	decoder = NewDecoder(nil, data, nil, -1)
+	decoder.TraceEnabled = true
+	if _, err := decoder.DecodeAll(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	kinds := []TraceKind{TraceHeader, TracePartHeader, TraceBody, TraceTrailer, TraceValidated}
+	if len(decoder.Trace) != 3*len(kinds) {
+		t.Fatalf("expected %d events got %v", 3*len(kinds), decoder.Trace)
+	}
+	for i, ev := range decoder.Trace {
+		if ev.Kind != kinds[i%len(kinds)] || ev.Part != i/len(kinds)+1 {
+			t.Errorf("event %d: unexpected %s", i, ev)
+		}
+	}
+	if last := decoder.Trace[len(decoder.Trace)-1]; last.Offset != int64(len(data)) {
+		t.Errorf("expected the trace to end at offset %d got %d", len(data), last.Offset)
+	}
+
+	truncated := loadFixture(t, "truncated_test.yenc")
+	decoder = NewDecoder(nil, truncated, nil, -1)
+	decoder.TraceEnabled = true
+	if _, err := decoder.Decode(); err == nil {
+		t.Fatal("expected truncated_test.yenc to fail")
+	}
+	if n := len(decoder.Trace); n == 0 || decoder.Trace[n-1].Kind != TraceError || decoder.Trace[0].Kind != TraceHeader {
+		t.Errorf("expected the trace to end with an error got %v", decoder.Trace)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	data := loadFixture(t, "multipart_full_test.yenc")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+	calls := 0
+	passThrough := func(r io.Reader) (io.Reader, error) {
+		calls++
+		return r, nil
+	}
+	gunzip := func(r io.Reader) (io.Reader, error) {
+		calls++
+		return gzip.NewReader(r)
+	}
+	for _, tc := range []struct {
+		input      []byte
+		decompress func(io.Reader) (io.Reader, error)
+	}{
+		{data, passThrough},
+		{gz.Bytes(), gunzip},
+	} {
+		calls = 0
+		decoder := NewDecoder(bytes.NewReader(tc.input), nil, nil, -1)
+		decoder.Decompress = tc.decompress
+		parts, err := decoder.DecodeAll()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if len(parts) != 3 || !decoder.Validated() || calls != 1 {
+			t.Errorf("expected 3 validated parts and one call got %d parts and %d calls", len(parts), calls)
+		}
+	}
+	decoder := NewDecoder(bytes.NewReader(data), nil, nil, -1)
+	decoder.Decompress = gunzip
+	if _, err := decoder.DecodeAll(); !errors.Is(err, gzip.ErrHeader) {
+		t.Errorf("expected gzip.ErrHeader got %v", err)
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	data := loadFixture(t, "someerrors_test.yenc")
+	if _, err := NewDecoder(nil, data, nil, -1).DecodeAll(); !errors.Is(err, ErrCRCMismatch) || errors.Is(err, ErrSizeExceeded) {
+		t.Errorf("expected to stop at the first error got %v", err)
+	}
+	decoder := NewDecoder(nil, data, nil, -1)
+	decoder.ContinueOnError = true
+	parts, err := decoder.DecodeAll()
+	if !errors.Is(err, ErrCRCMismatch) || !errors.Is(err, ErrSizeExceeded) {
+		t.Errorf("expected both errors joined got %v", err)
+	}
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Errorf("expected the DecodeError of the size error got %v", err)
+	}
+	if len(parts) != 3 || parts[0].Name != "good1.bin" || parts[1].Name != "good2.bin" || parts[2].Name != "good3.bin" {
+		t.Errorf("expected the 3 good parts got %d", len(parts))
+	}
+	if decoder.Validated() {
+		t.Error("expected Validated to be false with errors")
+	}
+	// a bad copy of part 2 followed by a good one
+	full := loadFixture(t, "multipart_full_test.yenc")
+	lines := bytes.SplitAfter(full, []byte("\r\n"))
+	bad := bytes.Clone(lines[32])
+	i := bytes.IndexFunc(bad, func(r rune) bool { return r != '=' && r != 'A' })
+	for bad[i+1] == '=' || i > 0 && bad[i-1] == '=' {
+		i++
+	}
+	bad[i] = 'A'
+	var input []byte
+	for _, l := range slices.Concat(lines[:32], [][]byte{bad}, lines[33:60], lines[30:]) {
+		input = append(input, l...)
+	}
+	decoder = NewDecoder(nil, input, nil, -1)
+	decoder.ContinueOnError = true
+	parts, err = decoder.DecodeAll()
+	if !errors.Is(err, ErrCRCMismatch) || strings.Contains(err.Error(), "already processed") || strings.Contains(err.Error(), "full file crc") {
+		t.Errorf("expected only the crc error of the bad copy got %v", err)
+	}
+	if len(parts) != 3 || parts[1].Number != 2 || !decoder.verified["random.bin"] {
+		t.Errorf("expected the 3 good parts and the full file crc checked got %d", len(parts))
+	}
+}
+
+// flushCounter counts the writes bufio.Writer passes through on Flush
+type flushCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (f *flushCounter) Write(b []byte) (int, error) {
+	f.writes++
+	return f.Buffer.Write(b)
+}
+
+func TestDecodeToBuffered(t *testing.T) {
+	data := loadFixture(t, "singlepart_test.yenc")
+	// 584 bytes: 5 flushes of 100 bytes and the final one of 84
+	sink := &flushCounter{}
+	bw := bufio.NewWriterSize(sink, 4096)
+	part, err := NewDecoder(bytes.NewReader(data), nil, nil, -1).DecodeToBuffered(bw, 100)
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if sink.Len() != 584 || part.Body != nil {
+		t.Errorf("expected 584 bytes written and no body got %d and %d", sink.Len(), len(part.Body))
+	}
+	if sink.writes != 6 {
+		t.Errorf("expected 6 flushes got %d", sink.writes)
+	}
+	sink = &flushCounter{}
+	bw = bufio.NewWriterSize(sink, 4096)
+	if _, err := NewDecoder(bytes.NewReader(data), nil, nil, -1).DecodeToBuffered(bw, 0); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if sink.writes != 1 || sink.Len() != 584 {
+		t.Errorf("expected the final flush only got %d", sink.writes)
+	}
+}
+
+// malformedArticles returns the cases of malformedheaders_test.txt:
+// the first part of multipart_test.yenc with one line replaced
+// and the name of the error expected from decoding it.
+func malformedArticles(tb testing.TB) (articles [][]byte, wants []string) {
+	seeds := loadFixture(tb, "malformedheaders_test.txt")
+	multi := loadFixture(tb, "multipart_test.yenc")
+	lines := bytes.Split(multi, []byte("\r\n"))
+	// =ybegin, =ypart and =yend of the first part
+	at := map[string]int{"=ybegin": 0, "=ypart": 1, "=yend": 93}
+	for _, seed := range strings.Split(string(seeds), "\n") {
+		if seed == "" || seed[0] == '#' {
+			continue
+		}
+		want, line, _ := strings.Cut(seed, "\t")
+		key, _, _ := strings.Cut(strings.Replace(line, "\t", " ", 1), " ")
+		article := slices.Clone(lines[:94])
+		article[at[key]] = []byte(line)
+		articles = append(articles, append(bytes.Join(article, []byte("\r\n")), "\r\n"...))
+		wants = append(wants, want)
+	}
+	return articles, wants
+}
+
+func TestMalformedHeaders(t *testing.T) {
+	sentinels := map[string]error{
+		"ErrMissingSize":     ErrMissingSize,
+		"ErrMalformedHeader": ErrMalformedHeader,
+		"ErrMissingName":     ErrMissingName,
+		"ErrSizeExceeded":    ErrSizeExceeded,
+		"ErrMissingCRC":      ErrMissingCRC,
+		"ErrCRCMismatch":     ErrCRCMismatch,
+		"ErrSizeEncoded":     ErrSizeEncoded,
+	}
+	articles, wants := malformedArticles(t)
+	for i, article := range articles {
+		header, _, _ := bytes.Cut(article, []byte("\r\n"))
+		_, err := NewDecoder(nil, article, nil, 1).Decode()
+		var orderErr *ErrPartOutOfOrder
+		switch want := wants[i]; want {
+		case "ok":
+			if err != nil {
+				t.Errorf("case %d %q: expected to decode: %v", i, header, err)
+			}
+		case "ErrPartOutOfOrder":
+			if !errors.As(err, &orderErr) {
+				t.Errorf("case %d %q: expected %s got %v", i, header, want, err)
+			}
+		default:
+			sentinel, ok := sentinels[want]
+			if !ok {
+				t.Fatalf("case %d: unknown error %s", i, want)
+			}
+			if !errors.Is(err, sentinel) {
+				t.Errorf("case %d %q: expected %s got %v", i, header, want, err)
+			}
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	articles, _ := malformedArticles(f)
+	for _, article := range articles {
+		f.Add(article)
+	}
+	for _, file := range []string{"singlepart_test.yenc", "multipart_test.yenc", "article_test.yenc"} {
+		f.Add(loadFixture(f, file))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// must not panic, errors are fine
+		NewDecoder(nil, data, nil, -1).DecodeAll()
+		NewDecoder(bytes.NewReader(data), nil, nil, -1).DecodeAll()
+	})
+}
+
+func TestSkipInvalidHeaders(t *testing.T) {
+	data := loadFixture(t, "staleheader_test.yenc")
+	if _, err := NewDecoder(bytes.NewReader(data), nil, nil, -1).Decode(); !errors.Is(err, ErrMissingSize) {
+		t.Errorf("expected ErrMissingSize without SkipInvalidHeaders got %v", err)
+	}
+	single := loadFixture(t, "singlepart_test.yenc")
+	noname := append([]byte("=ybegin line=128 size=584 name=\r\n"), single...)
+	malformed := append([]byte("=ybegin line=128 size=5x4 name=testfile.txt\r\n"), single...)
+	for name, input := range map[string][]byte{"stale": data, "noname": noname, "malformed": malformed} {
+		decoder := NewDecoder(bytes.NewReader(input), nil, nil, -1)
+		decoder.SkipInvalidHeaders = true
+		result, err := decoder.DecodeResult()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", name, err.Error())
+		}
+		if result.Part.Name != "testfile.txt" || len(result.Part.Body) != 584 {
+			t.Errorf("%s: expected testfile.txt of 584 bytes got %q %d", name, result.Part.Name, len(result.Part.Body))
+		}
+		if len(result.Warnings) != 1 || result.Warnings[0].Kind != WarnSkippedHeader {
+			t.Errorf("%s: expected a WarnSkippedHeader got %v", name, result.Warnings)
+		}
+	}
+}
+
+func TestTiming(t *testing.T) {
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	decoder := NewDecoder(nil, multi, nil, -1)
+	decoder.Timing = true
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	for _, p := range parts {
+		if p.DecodeDuration <= 0 {
+			t.Errorf("expected DecodeDuration of part %d to be set", p.Number)
+		}
+	}
+	parts, err = NewDecoder(nil, multi, nil, -1).DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if parts[0].DecodeDuration != 0 {
+		t.Errorf("expected no DecodeDuration without Timing got %v", parts[0].DecodeDuration)
+	}
+}
+
+func TestPeekName(t *testing.T) {
+	data := loadFixture(t, "headers_test.yenc")
+	br := bufio.NewReader(bytes.NewReader(data))
+	name, err := PeekName(br)
+	if err != nil || name != "testfile.txt" {
+		t.Fatalf("expected testfile.txt got %q err=%v", name, err)
+	}
+	// the =ybegin line is still there
+	part, err := NewDecoderFromBufio(br, 1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Name != name || len(part.Body) != 584 {
+		t.Errorf("expected %s of 584 bytes got %s of %d", name, part.Name, len(part.Body))
+	}
+	// a long line in front of =ybegin does not fit the buffer
+	single := loadFixture(t, "singlepart_test.yenc")
+	long := append(append(bytes.Repeat([]byte("x"), 200), "=ybegin line=1 size=1 name=no.bin\r\n"...), single...)
+	if name, err := PeekName(bufio.NewReaderSize(bytes.NewReader(long), 64)); err != nil || name != "testfile.txt" {
+		t.Errorf("expected testfile.txt after a long line got %q err=%v", name, err)
+	}
+	// headers without a blank line in front of =ybegin
+	if name, err := PeekName(bytes.NewReader(append([]byte("Name: x\r\n"), single...))); err != nil || name != "testfile.txt" {
+		t.Errorf("expected testfile.txt after a header line got %q err=%v", name, err)
+	}
+	if _, err := PeekName(strings.NewReader("no yenc here\r\n")); err == nil {
+		t.Error("expected an error without =ybegin")
+	}
+	if _, err := PeekName(strings.NewReader("=ybegin line=128 size=1 name=\r\n")); !errors.Is(err, ErrMissingName) {
+		t.Errorf("expected ErrMissingName got %v", err)
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	data := make([]byte, 5000)
+	rand.New(rand.NewSource(216)).Read(data)
+	for _, opts := range []*EncodeOptions{
+		{Name: "single.bin"},
+		{Name: "multi.bin", Size: 15000, Part: 2, Total: 3, Begin: 5001, End: 10000},
+	} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, data, opts); err != nil {
+			t.Fatalf("expected to encode: %v", err)
+		}
+		part, err := NewDecoder(nil, buf.Bytes(), nil, -1).Decode()
+		if err != nil {
+			t.Fatalf("expected to decode: %v", err.Error())
+		}
+		if got := part.EncodedSize(); got != int64(buf.Len()) {
+			t.Errorf("%s: expected encoded size %d got %d", opts.Name, buf.Len(), got)
+		}
+		// a segment bytes= with some article headers
+		if err := part.VerifyEncodedSize(int64(buf.Len())+300, 0.1); err != nil {
+			t.Errorf("%s: expected to be within tolerance: %v", opts.Name, err)
+		}
+		if err := part.VerifyEncodedSize(int64(buf.Len())*2, 0.1); !errors.Is(err, ErrEncodedSizeMismatch) {
+			t.Errorf("%s: expected ErrEncodedSizeMismatch got %v", opts.Name, err)
+		}
+	}
+}
+
+func TestAutoLineEnding(t *testing.T) {
+	want := loadFixture(t, "singlepart_test.yenc")
+	wantPart, err := NewDecoder(nil, want, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	for file, ending := range map[string]LineEnding{
+		"singlepart_test.yenc":  EndingCRLF,
+		"lfonly_test.yenc":      EndingLF,
+		"cronly_test.yenc":      EndingCR,
+		"mixedending_test.yenc": EndingMixed,
+	} {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("could not open %s for testing", file)
+		}
+		decoder := NewDecoder(f, nil, nil, -1)
+		decoder.AutoLineEnding = true
+		part, err := decoder.Decode()
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", file, err.Error())
+		}
+		if !bytes.Equal(part.Body, wantPart.Body) {
+			t.Errorf("%s: expected the same %d bytes got %d", file, len(wantPart.Body), len(part.Body))
+		}
+		if decoder.LineEnding() != ending {
+			t.Errorf("%s: expected line ending %v got %v", file, ending, decoder.LineEnding())
+		}
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_test.yenc")
+	var rar bytes.Buffer
+	if err := Encode(&rar, []byte("Rar!\x1a\x07\x01\x00 rest of the archive"), &EncodeOptions{Name: "holiday.jpg"}); err != nil {
+		t.Fatalf("expected to encode: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		data     []byte
+		mismatch bool
+	}{
+		"jpg":            {multi, false},
+		"rar as jpg":     {rar.Bytes(), true},
+		"text as png":    {bytes.Replace(single, []byte("name=testfile.txt"), []byte("name=testfile.PNG"), 1), true},
+		"unknown suffix": {single, false},
+	} {
+		decoder := NewDecoder(nil, tc.data, nil, 1)
+		decoder.SniffContentType = true
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", name, err.Error())
+		}
+		if part.TypeMismatch != tc.mismatch {
+			t.Errorf("%s: expected TypeMismatch=%t", name, tc.mismatch)
+		}
+	}
+}
+
+func TestDecoderPool(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	var pool DecoderPool
+	// misused without input
+	decoder := pool.Get()
+	if _, err := decoder.Decode(); !errors.Is(err, ErrNoInput) {
+		t.Errorf("expected ErrNoInput got %v", err)
+	}
+	decoder.SetBytes(single)
+	if _, err := decoder.Decode(); err != nil {
+		t.Errorf("expected to decode once the input is set: %v", err.Error())
+	}
+	pool.Put(decoder)
+	// the read buffer is kept for the next input
+	decoder = pool.Get()
+	decoder.SetBytes(single)
+	buf := decoder.Buf
+	decoder.SkipCRC = true
+	if _, err := decoder.Decode(); err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	pool.Put(decoder)
+	if decoder.SkipCRC || len(decoder.parts) != 0 || decoder.spareBuf != buf {
+		t.Errorf("expected Put to clear options and state and keep the buffer")
+	}
+	decoder.SetBytes(multi)
+	if decoder.Buf != buf {
+		t.Errorf("expected the buffer to be reused")
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				decoder := pool.Get()
+				if (g+i)%2 == 0 {
+					decoder.SetBytes(single)
+					part, err := decoder.Decode()
+					if err != nil || part.Name != "testfile.txt" || len(part.Body) != 584 {
+						t.Errorf("expected testfile.txt got err=%v", err)
+					}
+				} else {
+					decoder.SetReader(bytes.NewReader(multi))
+					parts, err := decoder.DecodeAll()
+					if err != nil || len(parts) != 3 || !decoder.Validated() {
+						t.Errorf("expected 3 validated parts got %d err=%v", len(parts), err)
+					}
+				}
+				pool.Put(decoder)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestDecodeWithRaw(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	input := append([]byte("Subject: test\r\n\r\nsome text\r\n"), single...)
+	input = append(input, multi...)
+	part, raw, err := DecodeWithRaw(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !bytes.Equal(raw, single) {
+		t.Errorf("expected the %d bytes of the article got %d", len(single), len(raw))
+	}
+	again, err := NewDecoder(nil, raw, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected the raw article to decode: %v", err.Error())
+	}
+	if !bytes.Equal(again.Body, part.Body) || again.Name != part.Name {
+		t.Errorf("expected the same part from the raw article")
+	}
+}
+
+func TestDatCRLFLines(t *testing.T) {
+	for file, crc := range map[string]string{"singlepart_test.yenc": "ded29f4f", "multipart_test.yenc": "bfae5c0b"} {
+		data := loadFixture(t, file)
+		// the lines keep their CRLF
+		var lines []*string
+		for _, l := range strings.SplitAfter(string(data), "\n") {
+			lines = append(lines, &l)
+		}
+		part, err := NewDecoder(nil, nil, lines, 1).Decode()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", file, err.Error())
+		}
+		if !part.crcSet || part.ExpectedCRCHex() != crc || part.CRCHex() != crc {
+			t.Errorf("%s: expected crc %s from the trailer got %s computed %s", file, crc, part.ExpectedCRCHex(), part.CRCHex())
+		}
+		if strings.HasSuffix(part.RawEnd, "\r") {
+			t.Errorf("%s: expected RawEnd without CR got %q", file, part.RawEnd)
+		}
+	}
+}
+
+func TestDecodeRange(t *testing.T) {
+	data := loadFixture(t, "multipart_test.yenc")
+	full, err := NewDecoder(nil, data, nil, 1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	for _, r := range [][2]int64{{5000, 1234}, {0, 10}, {11000, 1000}, {20000, 10}} {
+		part, err := DecodeRange(bytes.NewReader(data), r[0], r[1])
+		if err != nil {
+			t.Fatalf("expected to decode range %v: %v", r, err.Error())
+		}
+		start, end := min(r[0], int64(len(full.Body))), min(r[0]+r[1], int64(len(full.Body)))
+		if !bytes.Equal(part.Body, full.Body[start:end]) {
+			t.Errorf("range %v: expected %d bytes got %d", r, end-start, len(part.Body))
+		}
+		if part.bodyLen() != end-start {
+			t.Errorf("range %v: expected length %d got %d", r, end-start, part.bodyLen())
+		}
+	}
+	// start+length overflows int64
+	if part, err := DecodeRange(bytes.NewReader(data), 2, math.MaxInt64); err != nil || !bytes.Equal(part.Body, full.Body[2:]) {
+		t.Errorf("expected everything from byte 2 on err=%v", err)
+	}
+	if _, err := DecodeRange(bytes.NewReader(data), -1, 10); err == nil {
+		t.Error("expected an error for a negative start")
+	}
+}
+
+func TestDefaultLineLength(t *testing.T) {
+	data := loadFixture(t, "noline_test.yenc")
+	result, err := NewDecoder(nil, data, nil, -1).DecodeResult()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	part := result.Part
+	if part.LineLength() != DefaultLine {
+		t.Errorf("expected line length %d got %d", DefaultLine, part.LineLength())
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("expected no warnings got %v", result.Warnings)
+	}
+	if n := EstimateDecodedSize(int(part.RawSize()), part.LineLength()); n <= 0 {
+		t.Errorf("expected an estimate got %d", n)
+	}
+	rewrapped, err := Rewrap(data, part.LineLength())
+	if err != nil {
+		t.Fatalf("expected to rewrap: %v", err)
+	}
+	again, err := NewDecoder(nil, rewrapped, nil, -1).Decode()
+	if err != nil || !bytes.Equal(again.Body, part.Body) {
+		t.Errorf("expected the rewrapped article to decode to the same body err=%v", err)
+	}
+	var buf bytes.Buffer
+	if err := part.Encode(&buf, nil); err != nil || !bytes.Contains(buf.Bytes(), []byte("line=128")) {
+		t.Errorf("expected to encode with line=128 err=%v", err)
+	}
+}
+
+func TestDecodeToFile(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	want, err := NewDecoder(nil, single, nil, -1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	dir := t.TempDir()
+	// into a directory as SafeName
+	if _, err := DecodeToFile(bytes.NewReader(single), dir); err != nil {
+		t.Fatalf("expected to decode to file: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "testfile.txt"))
+	if err != nil || !bytes.Equal(got, want.Body) {
+		t.Errorf("expected testfile.txt with %d bytes got %d err=%v", len(want.Body), len(got), err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "testfile.txt")); err != nil {
+		t.Errorf("expected testfile.txt: %v", err)
+	} else if fi.Mode().Perm() != 0644 {
+		t.Errorf("expected mode 0644 got %v", fi.Mode().Perm())
+	}
+	// a crc failure leaves nothing behind
+	bad := bytes.Replace(single, []byte("crc32=ded29f4f"), []byte("crc32=ded29f40"), 1)
+	badDir := t.TempDir()
+	if _, err := DecodeToFile(bytes.NewReader(bad), filepath.Join(badDir, "out.txt")); !errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected ErrCRCMismatch got %v", err)
+	}
+	if entries, _ := os.ReadDir(badDir); len(entries) != 0 {
+		t.Errorf("expected no files after a failed decode got %d", len(entries))
+	}
+}
+
+func TestUnknownMarkers(t *testing.T) {
+	data := loadFixture(t, "unknownmarker_test.yenc")
+	lines := splitLines(string(data))
+	for name, decoder := range map[string]*Decoder{
+		"reader": NewDecoder(bytes.NewReader(data), nil, nil, -1),
+		"lines":  NewDecoder(nil, nil, lines, -1),
+	} {
+		part, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("%s: expected to decode: %v", name, err.Error())
+		}
+		if len(part.Body) != 584 || part.CRCHex() != "ded29f4f" {
+			t.Errorf("%s: expected the markers not to be decoded got %d bytes crc %s", name, len(part.Body), part.CRCHex())
+		}
+		if !slices.Equal(part.UnknownMarkers, []string{"=yfoo bar=1", "=ydata kind=comment"}) {
+			t.Errorf("%s: expected the markers to be recorded got %q", name, part.UnknownMarkers)
+		}
+	}
+}
+
+func TestValidateJoinsErrors(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	// announces more bytes than the body has and the wrong crc
+	bad := bytes.Replace(single, []byte("=yend size=584 crc32=ded29f4f"), []byte("=yend size=600 crc32=ded29f40"), 1)
+	_, err := NewDecoder(nil, bad, nil, -1).Decode()
+	if !errors.Is(err, ErrTruncatedBeforeTrailer) || !errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected both the size and the crc error got %v", err)
+	}
+	// only the crc is wrong
+	bad = bytes.Replace(single, []byte("crc32=ded29f4f"), []byte("crc32=ded29f40"), 1)
+	_, err = NewDecoder(nil, bad, nil, -1).Decode()
+	if errors.Is(err, ErrTruncatedBeforeTrailer) || !errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected only the crc error got %v", err)
+	}
+}
+
+func TestDecodeChan(t *testing.T) {
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	parts, errc := DecodeChan(bytes.NewReader(multi))
+	var numbers []int
+	for part := range parts {
+		numbers = append(numbers, part.Number)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if !slices.Equal(numbers, []int{1, 2, 3}) {
+		t.Errorf("expected parts 1, 2, 3 in order got %v", numbers)
+	}
+
+	// good1.bin then a crc failure
+	some := loadFixture(t, "someerrors_test.yenc")
+	parts, errc = DecodeChan(bytes.NewReader(some))
+	var names []string
+	for part := range parts {
+		names = append(names, part.Name)
+	}
+	if err := <-errc; !errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected ErrCRCMismatch got %v", err)
+	}
+	if !slices.Equal(names, []string{"good1.bin"}) {
+		t.Errorf("expected good1.bin before the error got %v", names)
+	}
+}
+
+func TestVerifyAgainstReader(t *testing.T) {
+	data := loadFixture(t, "multipart_test.yenc")
+	part, err := NewDecoder(nil, data, nil, 1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	decoder := NewDecoder(nil, data, nil, 1)
+	decoder.ChunkSize = 1000
+	chunked, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	differs := bytes.Clone(part.Body)
+	differs[7000] ^= 1
+	for _, p := range []*Part{part, chunked} {
+		for _, tc := range []struct {
+			ref   []byte
+			equal bool
+			off   int64
+		}{
+			{part.Body, true, -1},
+			{differs, false, 7000},
+			{part.Body[:5000], false, 5000},
+			{append(bytes.Clone(part.Body), 0), false, int64(len(part.Body))},
+		} {
+			equal, off, err := p.VerifyAgainstReader(bytes.NewReader(tc.ref))
+			if err != nil || equal != tc.equal || off != tc.off {
+				t.Errorf("expected equal=%t at %d got %t at %d err=%v", tc.equal, tc.off, equal, off, err)
+			}
+		}
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	multi := loadFixture(t, "multipart_test.yenc")
+	part, err := NewDecoder(nil, multi, nil, 1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	single := loadFixture(t, "singlepart_test.yenc")
+	singlePart, err := NewDecoder(nil, single, nil, 1).Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	for _, p := range []*Part{part, singlePart} {
+		if r := p.Integrity(); !r.OK() || r.ComputedCRC != p.Crc32 || r.ExpectedCRC != p.Crc32 {
+			t.Errorf("expected a healthy part %d got %+v", p.Number, r)
+		}
+	}
+	badSize, badCRC, badRange := *part, *part, *part
+	badSize.Size++
+	badCRC.Crc32 ^= 1
+	badRange.End--
+	for name, tc := range map[string]struct {
+		part *Part
+		want IntegrityReport
+	}{
+		"size":  {&badSize, IntegrityReport{SizeOK: false, CRCOK: true, BeginEndOK: true}},
+		"crc":   {&badCRC, IntegrityReport{SizeOK: true, CRCOK: false, BeginEndOK: true}},
+		"range": {&badRange, IntegrityReport{SizeOK: true, CRCOK: true, BeginEndOK: false}},
+	} {
+		r := tc.part.Integrity()
+		if r.SizeOK != tc.want.SizeOK || r.CRCOK != tc.want.CRCOK || r.BeginEndOK != tc.want.BeginEndOK || r.OK() {
+			t.Errorf("%s: expected %+v got %+v", name, tc.want, r)
+		}
+	}
+}
+
+func TestBase64Transport(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	// wrapped at 76 columns like MIME
+	encoded := base64.StdEncoding.EncodeToString(single)
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded + "\r\n")
+	decoder := NewDecoder(strings.NewReader(wrapped.String()), nil, nil, -1)
+	decoder.Base64Transport = true
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if part.Name != "testfile.txt" || len(part.Body) != 584 || part.CRCHex() != "ded29f4f" {
+		t.Errorf("expected testfile.txt of 584 bytes got %s of %d", part.Name, len(part.Body))
+	}
+	decoder = NewDecoder(bytes.NewReader(single), nil, nil, -1)
+	decoder.Base64Transport = true
+	var corrupt base64.CorruptInputError
+	if _, err := decoder.Decode(); !errors.As(err, &corrupt) {
+		t.Errorf("expected a base64.CorruptInputError got %v", err)
+	}
+}
+
+func TestPeakBytes(t *testing.T) {
+	single := loadFixture(t, "singlepart_test.yenc")
+	decoder := NewDecoder(nil, single, nil, -1)
+	part, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	if decoder.PeakBytes() != int64(len(part.Body)) || decoder.PeakBytes() != 584 {
+		t.Errorf("expected a peak of 584 bytes got %d", decoder.PeakBytes())
+	}
+	multi := loadFixture(t, "multipart_full_test.yenc")
+	decoder = NewDecoder(nil, multi, nil, -1)
+	parts, err := decoder.DecodeAll()
+	if err != nil {
+		t.Fatalf("expected to decode: %v", err.Error())
+	}
+	var largest int64
+	for _, p := range parts {
+		largest = max(largest, int64(len(p.Body)))
+	}
+	if decoder.PeakBytes() != largest {
+		t.Errorf("expected the largest part %d got %d", largest, decoder.PeakBytes())
+	}
+	decoder.Reset()
+	if decoder.PeakBytes() != 0 {
+		t.Errorf("expected Reset to clear the peak")
+	}
+}
+
+func BenchmarkDecodeEscapeLight(b *testing.B) {
+	data := bytes.Repeat([]byte("yEnc escape light benchmark payload "), 32*1024)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, &EncodeOptions{Name: "bench.txt"}); err != nil {
+		b.Fatalf("expected to encode: %v", err)
+	}
+	encoded := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decoder := NewDecoder(nil, encoded, nil, -1)
+		if _, err := decoder.Decode(); err != nil {
+			b.Fatalf("expected to decode: %v", err)
+		}
+	}
+}